@@ -0,0 +1,277 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sideprotocol/shuttler/app/store"
+	"go.uber.org/zap"
+)
+
+// Prefix of the admin listener for a unix socket
+const unixSocketPrefix = "unix://"
+
+// AdminStatus is the status of the running relayer returned by the admin API
+type AdminStatus struct {
+	LastHeader    *store.Header `json:"last_header"`
+	ScannedHeight int32         `json:"scanned_height"`
+	Synced        bool          `json:"synced"`
+	Halted        string        `json:"halted,omitempty"`
+	RelayPaused   bool          `json:"relay_paused"`
+	SigningPaused bool          `json:"signing_paused"`
+	Sequence      uint64        `json:"sequence"`
+	PendingTxs    []PendingTx   `json:"pending_txs"`
+}
+
+// PendingTx is a Side transaction accepted by the mempool, but not yet included in a block
+type PendingTx struct {
+	Sequence uint64 `json:"sequence"`
+	TxHash   string `json:"txhash"`
+}
+
+// Response of the admin control operations
+type adminResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (a *State) isRelayPaused() bool {
+	a.status.RLock()
+	defer a.status.RUnlock()
+	return a.status.relayPaused
+}
+
+func (a *State) isSigningPaused() bool {
+	a.status.RLock()
+	defer a.status.RUnlock()
+	return a.status.signingPaused
+}
+
+// Pause or resume the header relay
+// On resume, a halt is cleared, as the operator is expected to have resolved its cause,
+// and the light client is synced again before relaying new blocks.
+func (a *State) setRelayPaused(paused bool) {
+	a.status.Lock()
+	defer a.status.Unlock()
+	a.status.relayPaused = paused
+	if !paused {
+		a.status.synced = false
+		a.status.haltErr = nil
+	}
+}
+
+func (a *State) setSigningPaused(paused bool) {
+	a.status.Lock()
+	defer a.status.Unlock()
+	a.status.signingPaused = paused
+}
+
+// AdminRequests returns the operations requested by the admin API
+// They are run by the main loop of the relayer, so they don't race with the block processing.
+func (a *State) AdminRequests() <-chan func() {
+	return a.adminRequests
+}
+
+// Run the operation on the main loop and wait for the result
+func (a *State) runOnMainLoop(ctx context.Context, fn func() error) error {
+	result := make(chan error, 1)
+	select {
+	case a.adminRequests <- func() { result <- fn() }:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status of the running relayer
+func (a *State) adminStatus() (*AdminStatus, error) {
+	header, err := a.store.LastHeader()
+	if err != nil {
+		return nil, err
+	}
+	scanned, _, err := a.store.ScannedHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &AdminStatus{
+		LastHeader:    header,
+		ScannedHeight: scanned,
+		Synced:        a.isSynced(),
+		RelayPaused:   a.isRelayPaused(),
+		SigningPaused: a.isSigningPaused(),
+		PendingTxs:    []PendingTx{},
+	}
+	if err := a.halted(); err != nil {
+		status.Halted = err.Error()
+	}
+
+	a.seq.Lock()
+	status.Sequence = a.seq.next
+	for seq, hash := range a.seq.pending {
+		status.PendingTxs = append(status.PendingTxs, PendingTx{Sequence: seq, TxHash: hash})
+	}
+	a.seq.Unlock()
+	sort.Slice(status.PendingTxs, func(i, j int) bool { return status.PendingTxs[i].Sequence < status.PendingTxs[j].Sequence })
+
+	return status, nil
+}
+
+// Rescan the confirmed blocks for vault transactions from the height
+// The scanner cursor is moved back, and the blocks are scanned by the main loop a few at a time,
+// so that the header relay goes on during the rescan. Transactions already submitted are skipped.
+func (a *State) rescanFrom(height int32) error {
+	tip, err := a.QueryChainTip()
+	if err != nil {
+		return err
+	}
+	if height > int32(tip.Height) {
+		return fmt.Errorf("rescan height %d is above the light client tip %d", height, tip.Height)
+	}
+	return a.resetScanCursor(height)
+}
+
+// Move the scanner cursor back, so that the next height scanned is the given height
+// The cursor is never moved forward, that would skip the blocks not scanned yet.
+func (a *State) resetScanCursor(height int32) error {
+	if height <= 0 {
+		return fmt.Errorf("invalid rescan height: %d", height)
+	}
+	scanned, ok, err := a.store.ScannedHeight()
+	if err != nil {
+		return err
+	}
+	if ok && height > scanned+1 {
+		return fmt.Errorf("rescan height %d is ahead of the scanner, the next height scanned is %d", height, scanned+1)
+	}
+	a.Log.Info("Rescanning vault transactions", zap.Int32("from", height))
+	return a.store.SetScannedHeight(height - 1)
+}
+
+// Handler of the admin API
+func (a *State) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		status, err := a.adminStatus()
+		if err != nil {
+			writeAdminResponse(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	})
+
+	control := func(pattern string, fn func(r *http.Request) error) {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			a.Log.Info("Admin request", zap.String("path", r.URL.Path), zap.String("query", r.URL.RawQuery))
+			writeAdminResponse(w, fn(r))
+		})
+	}
+
+	control("/relay/pause", func(*http.Request) error {
+		a.setRelayPaused(true)
+		return nil
+	})
+	control("/relay/resume", func(*http.Request) error {
+		a.setRelayPaused(false)
+		return nil
+	})
+	control("/signing/pause", func(*http.Request) error {
+		a.setSigningPaused(true)
+		return nil
+	})
+	control("/signing/resume", func(*http.Request) error {
+		a.setSigningPaused(false)
+		return nil
+	})
+	control("/rescan", func(r *http.Request) error {
+		from, err := strconv.ParseInt(r.URL.Query().Get("from"), 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid from height: %w", err)
+		}
+		return a.runOnMainLoop(r.Context(), func() error {
+			return a.rescanFrom(int32(from))
+		})
+	})
+	control("/withdrawals/sync", func(r *http.Request) error {
+		return a.runOnMainLoop(r.Context(), func() error {
+			a.SyncWithdrawalTxns()
+			return nil
+		})
+	})
+
+	return mux
+}
+
+func writeAdminResponse(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(adminResponse{Status: "error", Error: err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(adminResponse{Status: "ok"})
+}
+
+// Listen on the admin address, a unix socket relative to the home directory or a loopback address
+func (a *State) listenAdmin(listen string) (net.Listener, error) {
+	if strings.HasPrefix(listen, unixSocketPrefix) {
+		path := a.homeFile(strings.TrimPrefix(listen, unixSocketPrefix))
+		// Remove the stale socket of a previous run
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		ln, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			ln.Close()
+			return nil, err
+		}
+		return ln, nil
+	}
+
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("admin API must listen on a unix socket or a loopback address: %s", listen)
+	}
+	return net.Listen("tcp", listen)
+}
+
+// StartAdminServer serves the admin API on the configured listener, if any
+// The server is shut down when the context is done.
+func (a *State) StartAdminServer(ctx context.Context) error {
+	listen := a.Config.Global.AdminListen
+	if listen == "" {
+		return nil
+	}
+
+	ln, err := a.listenAdmin(listen)
+	if err != nil {
+		return err
+	}
+	a.serveHTTP(ctx, ln, a.adminHandler())
+	return nil
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/sideprotocol/shuttler/app/store"
+	"go.uber.org/zap"
+)
+
+func Test_AdminAPI(t *testing.T) {
+
+	s, err := store.Open(filepath.Join(t.TempDir(), store.DefaultDBName))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer s.Close()
+
+	a := NewAppState(t.TempDir())
+	a.Config = defaultConfig("signet")
+	a.Log = zap.NewNop()
+	a.store = s
+	a.setSynced(true)
+	a.commitSequence(7, "ABCD")
+
+	server := httptest.NewServer(a.adminHandler())
+	defer server.Close()
+
+	// pause the header relay
+	res, err := http.Post(server.URL+"/relay/pause", "", nil)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", res.StatusCode)
+	}
+
+	res, err = http.Get(server.URL + "/status")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer res.Body.Close()
+
+	status := &AdminStatus{}
+	if err := json.NewDecoder(res.Body).Decode(status); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !status.RelayPaused || status.SigningPaused || !status.Synced {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+	if status.Sequence != 8 || len(status.PendingTxs) != 1 || status.PendingTxs[0].TxHash != "ABCD" {
+		t.Errorf("Unexpected pending transactions: %+v", status)
+	}
+
+	// the relay is synced again on resume, and a halt is cleared
+	a.halt(errors.New("header rejected"))
+	a.setRelayPaused(false)
+	if a.isSynced() {
+		t.Errorf("Expected not synced after resume")
+	}
+	if err := a.halted(); err != nil {
+		t.Errorf("Expected the halt cleared after resume, got %v", err)
+	}
+
+	// rescan from the first block
+	if err := a.resetScanCursor(1); err != nil {
+		t.Fatalf("%v", err)
+	}
+	scanned, ok, err := s.ScannedHeight()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if next := nextScanHeight(scanned, ok, 100); next != 1 {
+		t.Errorf("Expected the rescan to start at 1, got %d", next)
+	}
+	if err := a.resetScanCursor(0); err == nil {
+		t.Errorf("Expected an error rescanning from 0")
+	}
+
+	// the cursor is never moved forward
+	if err := s.SetScannedHeight(50); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := a.resetScanCursor(52); err == nil {
+		t.Errorf("Expected an error rescanning ahead of the scanner")
+	}
+	if err := a.resetScanCursor(51); err != nil {
+		t.Errorf("Expected rescanning from the next height, got %v", err)
+	}
+	if scanned, _, _ := s.ScannedHeight(); scanned != 50 {
+		t.Errorf("Expected the scanned height 50, got %d", scanned)
+	}
+
+	// only loopback addresses are allowed
+	if _, err := a.listenAdmin("0.0.0.0:7070"); err == nil {
+		t.Errorf("Expected an error for a public address")
+	}
+}
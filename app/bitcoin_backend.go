@@ -0,0 +1,113 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	BackendRPC     = "rpc"
+	BackendEsplora = "esplora"
+)
+
+// BitcoinBackend reads the bitcoin network and broadcasts transactions
+// The bitcoind RPC client is the default implementation
+type BitcoinBackend interface {
+	GetBestBlockHash() (*chainhash.Hash, error)
+	GetBlockHash(blockHeight int64) (*chainhash.Hash, error)
+	GetBlockHeaderVerbose(blockHash *chainhash.Hash) (*btcjson.GetBlockHeaderVerboseResult, error)
+	GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error)
+	GetRawTransaction(txHash *chainhash.Hash) (*btcutil.Tx, error)
+	GetRawTransactionVerbose(txHash *chainhash.Hash) (*btcjson.TxRawResult, error)
+	SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error)
+}
+
+var _ BitcoinBackend = (*rpcclient.Client)(nil)
+
+// Create the bitcoin backend configured in the [bitcoin] section
+func (a *State) newBitcoinBackend() (BitcoinBackend, error) {
+	quorum := a.Config.Bitcoin.Quorum
+
+	switch a.Config.Bitcoin.Backend {
+	case "", BackendRPC:
+		// The quorum is verified across the bitcoind nodes, there must be enough of them
+		if quorum > 1+len(a.Config.Bitcoin.Nodes) {
+			return nil, fmt.Errorf("quorum %d is larger than the number of bitcoin nodes %d", quorum, 1+len(a.Config.Bitcoin.Nodes))
+		}
+
+		primary := a.Config.Bitcoin.PrimaryNode()
+		if len(a.Config.Bitcoin.Nodes) == 0 {
+			return a.NewRPCClient(primary)
+		}
+
+		// Fail over between the rpc node and the additional nodes
+		nodes := []bitcoinNode{}
+		for _, n := range append([]BitcoinNode{primary}, a.Config.Bitcoin.Nodes...) {
+			client, err := a.NewRPCClient(n)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, bitcoinNode{name: n.RPC, backend: client})
+		}
+		return newFailoverBackend(a.Log, nodes), nil
+	case BackendEsplora:
+		if quorum > 1 {
+			return nil, fmt.Errorf("quorum %d requires the rpc backend with multiple nodes", quorum)
+		}
+		if a.Config.Bitcoin.EsploraURL == "" {
+			return nil, fmt.Errorf("esplora url not set")
+		}
+		return NewEsploraClient(a.Config.Bitcoin.EsploraURL), nil
+	default:
+		return nil, fmt.Errorf("unknown bitcoin backend: %s", a.Config.Bitcoin.Backend)
+	}
+}
+
+// NewRPCClient creates a bitcoind RPC client of the node
+// With the https protocol, TLS is enabled, and the certificate is pinned if rpc-cert is set.
+// With rpccookie set, the credentials are read from the bitcoind cookie file instead.
+func (a *State) NewRPCClient(node BitcoinNode) (*rpcclient.Client, error) {
+	cfg := &rpcclient.ConnConfig{
+		Host:         node.RPC,
+		HTTPPostMode: true,
+	}
+
+	switch node.Protocol {
+	case "", "http":
+		cfg.DisableTLS = true
+	case "https":
+		if node.RPCCert != "" {
+			cert, err := os.ReadFile(a.homeFile(node.RPCCert))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the bitcoin rpc certificate: %w", err)
+			}
+			cfg.Certificates = cert
+		}
+	default:
+		return nil, fmt.Errorf("unknown bitcoin rpc protocol: %s", node.Protocol)
+	}
+
+	if node.RPCCookie != "" {
+		cfg.CookiePath = a.homeFile(node.RPCCookie)
+	} else {
+		cfg.User = node.RPCUser
+		cfg.Pass = node.RPCPassword
+	}
+
+	return rpcclient.New(cfg, nil)
+}
+
+// Resolve the path relative to the home directory
+func (a *State) homeFile(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(a.HomePath, path)
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_NewRPCClient(t *testing.T) {
+
+	a := &State{Config: defaultConfig("signet"), HomePath: t.TempDir()}
+
+	node := a.Config.Bitcoin.PrimaryNode()
+	node.Protocol = "ftp"
+	if _, err := a.NewRPCClient(node); err == nil {
+		t.Errorf("Expected an error for an unknown protocol")
+	}
+
+	// the pinned certificate must exist
+	node.Protocol = "https"
+	node.RPCCert = "missing.cert"
+	if _, err := a.NewRPCClient(node); err == nil {
+		t.Errorf("Expected an error for a missing certificate")
+	}
+
+	// cookie auth, the cookie file is read on each request
+	if err := os.WriteFile(filepath.Join(a.HomePath, ".cookie"), []byte("__cookie__:secret"), 0600); err != nil {
+		t.Fatalf("%v", err)
+	}
+	node.RPCCert = ""
+	node.RPCCookie = ".cookie"
+	client, err := a.NewRPCClient(node)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	client.Shutdown()
+}
+
+func Test_NewBitcoinBackendQuorum(t *testing.T) {
+
+	a := &State{Config: defaultConfig("signet"), HomePath: t.TempDir()}
+
+	// a quorum can't be reached without enough nodes
+	a.Config.Bitcoin.Quorum = 2
+	if _, err := a.newBitcoinBackend(); err == nil {
+		t.Errorf("Expected an error for a quorum of 2 with a single node")
+	}
+
+	a.Config.Bitcoin.Backend = BackendEsplora
+	if _, err := a.newBitcoinBackend(); err == nil {
+		t.Errorf("Expected an error for a quorum with the esplora backend")
+	}
+}
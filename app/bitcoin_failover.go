@@ -0,0 +1,228 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"go.uber.org/zap"
+)
+
+const (
+	// Interval of checking the health of the bitcoin nodes
+	nodeHealthInterval = 30 * time.Second
+	// Max blocks a node can be behind the others before failing over
+	maxNodeLag = 2
+)
+
+// Bitcoin node of the failover backend
+type bitcoinNode struct {
+	name    string
+	backend BitcoinBackend
+}
+
+// Bitcoin backend over multiple bitcoind nodes
+// Calls go to the current node, and fail over to the next node on connection errors.
+// The nodes are checked periodically, a node that is down or lagging behind is replaced.
+// The lock only guards the current node and the last check, calls run concurrently.
+type failoverBackend struct {
+	sync.Mutex
+	log       *zap.Logger
+	nodes     []bitcoinNode
+	current   int
+	lastCheck time.Time
+}
+
+var _ BitcoinBackend = (*failoverBackend)(nil)
+
+func newFailoverBackend(log *zap.Logger, nodes []bitcoinNode) *failoverBackend {
+	return &failoverBackend{log: log, nodes: nodes}
+}
+
+// Check if the error is returned by the node itself, e.g. a block not found
+// These errors are the same on every node, so there is no failover
+func isNodeError(err error) bool {
+	var rpcErr *btcjson.RPCError
+	return errors.As(err, &rpcErr)
+}
+
+// Get the best height of the node
+func nodeHeight(node BitcoinBackend) (int32, error) {
+	hash, err := node.GetBestBlockHash()
+	if err != nil {
+		return 0, err
+	}
+	header, err := node.GetBlockHeaderVerbose(hash)
+	if err != nil {
+		return 0, err
+	}
+	return header.Height, nil
+}
+
+// Get the index of the current node
+func (f *failoverBackend) currentNode() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.current
+}
+
+// Fail over from the node to the next one, unless another call has already switched away from it
+func (f *failoverBackend) failover(from int, err error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.current != from {
+		return
+	}
+	f.log.Warn("Bitcoin node failed, failing over", zap.String("node", f.nodes[from].name), zap.Error(err))
+	f.current = (from + 1) % len(f.nodes)
+}
+
+// Check if the health check is due, only one caller gets true per interval
+func (f *failoverBackend) healthCheckDue() bool {
+	f.Lock()
+	defer f.Unlock()
+	if time.Since(f.lastCheck) <= nodeHealthInterval {
+		return false
+	}
+	f.lastCheck = time.Now()
+	return true
+}
+
+// Check the health of all nodes, and switch to the node with the highest tip
+// if the current node is down or lagging behind.
+func (f *failoverBackend) checkHealth() {
+	heights := make([]int32, len(f.nodes))
+	best := -1
+	for i, node := range f.nodes {
+		height, err := nodeHeight(node.backend)
+		if err != nil {
+			f.log.Warn("Bitcoin node is down", zap.String("node", node.name), zap.Error(err))
+			heights[i] = -1
+			continue
+		}
+		heights[i] = height
+		if best < 0 || height > heights[best] {
+			best = i
+		}
+	}
+
+	if best < 0 {
+		f.log.Error("All bitcoin nodes are down")
+		return
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	if heights[f.current] < 0 || heights[best]-heights[f.current] > maxNodeLag {
+		f.log.Warn("Switching bitcoin node",
+			zap.String("from", f.nodes[f.current].name),
+			zap.String("to", f.nodes[best].name),
+			zap.Int32("height", heights[best]),
+		)
+		f.current = best
+	}
+}
+
+// Call the current node, failing over to the next ones on connection errors
+func call[T any](f *failoverBackend, fn func(BitcoinBackend) (T, error)) (T, error) {
+	if f.healthCheckDue() {
+		f.checkHealth()
+	}
+
+	var result T
+	var err error
+	for i := 0; i < len(f.nodes); i++ {
+		current := f.currentNode()
+		result, err = fn(f.nodes[current].backend)
+		if err == nil || isNodeError(err) {
+			return result, err
+		}
+		f.failover(current, err)
+	}
+	return result, err
+}
+
+func (f *failoverBackend) GetBestBlockHash() (*chainhash.Hash, error) {
+	return call(f, func(b BitcoinBackend) (*chainhash.Hash, error) {
+		return b.GetBestBlockHash()
+	})
+}
+
+func (f *failoverBackend) GetBlockHash(blockHeight int64) (*chainhash.Hash, error) {
+	return call(f, func(b BitcoinBackend) (*chainhash.Hash, error) {
+		return b.GetBlockHash(blockHeight)
+	})
+}
+
+func (f *failoverBackend) GetBlockHeaderVerbose(blockHash *chainhash.Hash) (*btcjson.GetBlockHeaderVerboseResult, error) {
+	return call(f, func(b BitcoinBackend) (*btcjson.GetBlockHeaderVerboseResult, error) {
+		return b.GetBlockHeaderVerbose(blockHash)
+	})
+}
+
+func (f *failoverBackend) GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error) {
+	return call(f, func(b BitcoinBackend) (*wire.MsgBlock, error) {
+		return b.GetBlock(blockHash)
+	})
+}
+
+func (f *failoverBackend) GetRawTransaction(txHash *chainhash.Hash) (*btcutil.Tx, error) {
+	return call(f, func(b BitcoinBackend) (*btcutil.Tx, error) {
+		return b.GetRawTransaction(txHash)
+	})
+}
+
+func (f *failoverBackend) GetRawTransactionVerbose(txHash *chainhash.Hash) (*btcjson.TxRawResult, error) {
+	return call(f, func(b BitcoinBackend) (*btcjson.TxRawResult, error) {
+		return b.GetRawTransactionVerbose(txHash)
+	})
+}
+
+func (f *failoverBackend) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error) {
+	return call(f, func(b BitcoinBackend) (*chainhash.Hash, error) {
+		return b.SendRawTransaction(tx, allowHighFees)
+	})
+}
+
+// Verify the block hash at the height is agreed by at least quorum nodes
+func (f *failoverBackend) verifyBlockHash(height int64, hash string, quorum int) error {
+	agreed := 0
+	for _, node := range f.nodes {
+		h, err := node.backend.GetBlockHash(height)
+		if err != nil {
+			f.log.Warn("Failed to get block hash", zap.String("node", node.name), zap.Int64("height", height), zap.Error(err))
+			continue
+		}
+		if h.String() == hash {
+			agreed++
+		} else {
+			f.log.Warn("Bitcoin node disagrees on block hash", zap.String("node", node.name), zap.Int64("height", height), zap.String("hash", h.String()), zap.String("expected", hash))
+		}
+	}
+
+	if agreed < quorum {
+		return fmt.Errorf("block %s at height %d is agreed by %d nodes, quorum is %d", hash, height, agreed, quorum)
+	}
+	return nil
+}
+
+// Verify the blocks are agreed by the quorum of bitcoin nodes before relaying them
+// Does nothing unless a quorum is configured, newBitcoinBackend ensures there are enough nodes for it
+func (a *State) verifyQuorum(blocks []*btcjson.GetBlockHeaderVerboseResult) error {
+	f, ok := a.rpc.(*failoverBackend)
+	if !ok || a.Config.Bitcoin.Quorum <= 1 {
+		return nil
+	}
+
+	for _, block := range blocks {
+		if err := f.verifyBlockHash(int64(block.Height), block.Hash, a.Config.Bitcoin.Quorum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"go.uber.org/zap"
+)
+
+// Bitcoin backend with a fixed chain of block hashes
+type mockBackend struct {
+	down   bool
+	hashes []chainhash.Hash
+	blocks map[chainhash.Hash]*wire.MsgBlock
+}
+
+func (m *mockBackend) GetBestBlockHash() (*chainhash.Hash, error) {
+	if m.down {
+		return nil, errors.New("connection refused")
+	}
+	return &m.hashes[len(m.hashes)-1], nil
+}
+
+func (m *mockBackend) GetBlockHash(height int64) (*chainhash.Hash, error) {
+	if m.down {
+		return nil, errors.New("connection refused")
+	}
+	if height >= int64(len(m.hashes)) {
+		return nil, &btcjson.RPCError{Code: btcjson.ErrRPCOutOfRange, Message: "Block height out of range"}
+	}
+	return &m.hashes[height], nil
+}
+
+func (m *mockBackend) GetBlockHeaderVerbose(hash *chainhash.Hash) (*btcjson.GetBlockHeaderVerboseResult, error) {
+	if m.down {
+		return nil, errors.New("connection refused")
+	}
+	for i := range m.hashes {
+		if m.hashes[i] == *hash {
+			header := &btcjson.GetBlockHeaderVerboseResult{Hash: hash.String(), Height: int32(i)}
+			if i > 0 {
+				header.PreviousHash = m.hashes[i-1].String()
+			}
+			return header, nil
+		}
+	}
+	return nil, &btcjson.RPCError{Code: btcjson.ErrRPCBlockNotFound, Message: "Block not found"}
+}
+
+func (m *mockBackend) GetBlock(hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	if block, ok := m.blocks[*hash]; ok {
+		return block, nil
+	}
+	return nil, &btcjson.RPCError{Code: btcjson.ErrRPCBlockNotFound, Message: "Block not found"}
+}
+
+// The node has no txindex
+func (m *mockBackend) GetRawTransaction(*chainhash.Hash) (*btcutil.Tx, error) {
+	return nil, &btcjson.RPCError{Code: btcjson.ErrRPCNoTxInfo, Message: "No such mempool or blockchain transaction"}
+}
+
+func (m *mockBackend) GetRawTransactionVerbose(*chainhash.Hash) (*btcjson.TxRawResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockBackend) SendRawTransaction(*wire.MsgTx, bool) (*chainhash.Hash, error) {
+	return nil, errors.New("not implemented")
+}
+
+func mockChain(n int, fork byte) []chainhash.Hash {
+	hashes := make([]chainhash.Hash, n)
+	for i := range hashes {
+		hashes[i][0] = byte(i)
+		hashes[i][1] = fork
+	}
+	return hashes
+}
+
+func Test_FailoverBackend(t *testing.T) {
+
+	primary := &mockBackend{hashes: mockChain(10, 0)}
+	backup := &mockBackend{hashes: mockChain(10, 0)}
+	f := newFailoverBackend(zap.NewNop(), []bitcoinNode{{"primary", primary}, {"backup", backup}})
+
+	// fail over when the current node is down
+	primary.down = true
+	hash, err := f.GetBlockHash(5)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if *hash != backup.hashes[5] {
+		t.Errorf("Expected %s, got %s", backup.hashes[5], hash)
+	}
+	if f.current != 1 {
+		t.Errorf("Expected failover to the backup node")
+	}
+
+	// errors of the node itself are returned as is
+	_, err = f.GetBlockHash(20)
+	if !isNodeError(err) {
+		t.Errorf("Expected an RPC error, got %v", err)
+	}
+	if f.current != 1 {
+		t.Errorf("Expected no failover on RPC errors")
+	}
+
+	// switch to the node with the highest tip when lagging
+	primary.down = false
+	primary.hashes = mockChain(15, 0)
+	f.checkHealth()
+	if f.current != 0 {
+		t.Errorf("Expected switching back to the primary node")
+	}
+}
+
+func Test_VerifyBlockHash(t *testing.T) {
+
+	nodes := []bitcoinNode{
+		{"a", &mockBackend{hashes: mockChain(10, 0)}},
+		{"b", &mockBackend{hashes: mockChain(10, 0)}},
+		{"c", &mockBackend{hashes: mockChain(10, 1)}},
+	}
+	f := newFailoverBackend(zap.NewNop(), nodes)
+	hash := mockChain(10, 0)[5].String()
+
+	if err := f.verifyBlockHash(5, hash, 2); err != nil {
+		t.Errorf("Expected quorum of 2, got %v", err)
+	}
+	if err := f.verifyBlockHash(5, hash, 3); err == nil {
+		t.Errorf("Expected no quorum of 3")
+	}
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	zmqclient "github.com/ordishs/go-bitcoin"
+	"go.uber.org/zap"
+)
+
+const (
+	BlockSourceZMQ  = "zmq"
+	BlockSourcePoll = "poll"
+
+	// Topic of the new block notifications
+	hashblockTopic = "hashblock"
+)
+
+// BlockSource notifies the new blocks of the bitcoin network
+// Notifications are in the format of the ZMQ "hashblock" message: [topic, block hash, sequence]
+type BlockSource interface {
+	// Subscribe sends the notifications of new blocks to the channel
+	Subscribe(ch chan []string) error
+	// Close stops the notifications
+	Close()
+}
+
+// Create the block source configured in the [bitcoin] section
+// The polling block source requires the RPC client to be initialized
+func (a *State) NewBlockSource() (BlockSource, error) {
+	switch a.Config.Bitcoin.BlockSource {
+	case "", BlockSourceZMQ:
+		host := a.Config.Bitcoin.ZMQHost
+		port := a.Config.Bitcoin.ZMQPort
+		if host == "" || port == 0 {
+			return nil, fmt.Errorf("ZMQ host or port not set")
+		}
+		return newZMQBlockSource(host, port), nil
+	case BlockSourcePoll:
+		interval := time.Duration(a.Config.Bitcoin.PollInterval) * time.Second
+		if interval <= 0 {
+			interval = DefaultPollInterval * time.Second
+		}
+		return &pollBlockSource{app: a, interval: interval, done: make(chan struct{})}, nil
+	default:
+		return nil, fmt.Errorf("unknown block source: %s", a.Config.Bitcoin.BlockSource)
+	}
+}
+
+// Block source subscribing to the ZMQ notifications of bitcoind
+type zmqBlockSource struct {
+	zmq    *zmqclient.ZMQ
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newZMQBlockSource(host string, port int) *zmqBlockSource {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &zmqBlockSource{
+		zmq:    zmqclient.NewZMQWithContext(ctx, host, port),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (z *zmqBlockSource) Subscribe(ch chan []string) error {
+	// Count the notifications on the way to the channel
+	in := make(chan []string)
+	if err := z.zmq.Subscribe(hashblockTopic, in); err != nil {
+		return err
+	}
+	go func() {
+		for {
+			select {
+			case msg := <-in:
+				zmqMessagesReceived.Inc()
+				select {
+				case ch <- msg:
+				case <-z.ctx.Done():
+					return
+				}
+			case <-z.ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (z *zmqBlockSource) Close() {
+	z.cancel()
+}
+
+// Block source polling the best block hash of bitcoind
+// Blocks mined between two polls are filled in by the new block handler
+type pollBlockSource struct {
+	app      *State
+	interval time.Duration
+	done     chan struct{}
+}
+
+func (p *pollBlockSource) Subscribe(ch chan []string) error {
+	go p.poll(ch)
+	return nil
+}
+
+func (p *pollBlockSource) Close() {
+	close(p.done)
+}
+
+func (p *pollBlockSource) poll(ch chan []string) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	last := ""
+	sequence := 0
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			hash, err := p.app.rpc.GetBestBlockHash()
+			if err != nil {
+				p.app.Log.Error("Failed to get best block hash", zap.Error(err))
+				continue
+			}
+			if hash.String() == last {
+				continue
+			}
+			last = hash.String()
+
+			select {
+			case ch <- []string{hashblockTopic, last, strconv.Itoa(sequence)}:
+				sequence++
+			case <-p.done:
+				return
+			}
+		}
+	}
+}
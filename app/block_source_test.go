@@ -0,0 +1,78 @@
+package app
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// bitcoin backend whose chain can be changed while polled
+type mockPolledBackend struct {
+	mockBackend
+	mu sync.Mutex
+}
+
+func (m *mockPolledBackend) GetBestBlockHash() (*chainhash.Hash, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mockBackend.GetBestBlockHash()
+}
+
+func (m *mockPolledBackend) set(hashes []chainhash.Hash, down bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hashes = hashes
+	m.down = down
+}
+
+func Test_PollBlockSource(t *testing.T) {
+
+	a := mockState(t)
+	backend := &mockPolledBackend{mockBackend: mockBackend{hashes: mockChain(3, 0)}}
+	a.rpc = backend
+	a.Config.Bitcoin.BlockSource = BlockSourcePoll
+
+	source, err := a.NewBlockSource()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	poll := source.(*pollBlockSource)
+	poll.interval = 10 * time.Millisecond
+
+	ch := make(chan []string)
+	if err := source.Subscribe(ch); err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer source.Close()
+
+	expect := func(hash chainhash.Hash, sequence string) {
+		select {
+		case msg := <-ch:
+			if len(msg) != 3 || msg[0] != hashblockTopic || msg[1] != hash.String() || msg[2] != sequence {
+				t.Errorf("Expected the block %s with sequence %s, got %v", hash, sequence, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected the block %s", hash)
+		}
+	}
+	expectNone := func(when string) {
+		select {
+		case msg := <-ch:
+			t.Errorf("%s: unexpected notification %v", when, msg)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	expect(mockChain(3, 0)[2], "0")
+	expectNone("same tip")
+
+	// polling failures are skipped
+	backend.set(mockChain(3, 0), true)
+	expectNone("backend down")
+
+	chain := mockChain(4, 0)
+	backend.set(chain, false)
+	expect(chain[3], "1")
+}
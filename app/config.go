@@ -1,6 +1,7 @@
 package app
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -23,7 +24,16 @@ type Config struct {
 }
 
 type Global struct {
-	LogLevel string `toml:"log-level"                   comment:"log level of the daemon"`
+	LogLevel      string `toml:"log-level"              comment:"log level of the daemon"`
+	LogFormat     string `toml:"log-format"             comment:"log output format: auto, logfmt, json, console"`
+	LogFile       string `toml:"log-file"               comment:"Rotated log file relative to the home directory, e.g. logs/shuttler.log, disabled if empty"`
+	LogMaxSize    int    `toml:"log-max-size"           comment:"Max size in megabytes of the log file before it's rotated"`
+	LogMaxBackups int    `toml:"log-max-backups"        comment:"Max number of rotated log files to keep"`
+	LogMaxAge     int    `toml:"log-max-age"            comment:"Max days to keep the rotated log files"`
+	MetricsListen string `toml:"metrics-listen"         comment:"Listen address of the Prometheus metrics endpoint, e.g. 127.0.0.1:9100, disabled if empty"`
+	HealthListen  string `toml:"health-listen"          comment:"Listen address of the /healthz and /readyz endpoints, may be the same as metrics-listen, disabled if empty"`
+	ReadyMaxLag   int32  `toml:"ready-max-lag"          comment:"Max blocks the light client can be behind the bitcoin tip to be ready"`
+	AdminListen   string `toml:"admin-listen"           comment:"Listen address of the admin API, a unix socket relative to the home directory (e.g. unix://admin.sock) or a loopback address, disabled if empty"`
 }
 
 type Bitcoin struct {
@@ -32,51 +42,154 @@ type Bitcoin struct {
 	RPC         string `toml:"rpc"                      comment:"Bitcoin RPC endpoint"`
 	RPCUser     string `toml:"rpcuser"                  comment:"Bitcoin RPC user"`
 	RPCPassword string `toml:"rpcpassword"              comment:"Bitcoin RPC password"`
-	Protocol    string `toml:"protocol"                 comment:"Bitcoin RPC protocol"`
+	Protocol    string `toml:"protocol"                 comment:"Bitcoin RPC protocol: http, https"`
+	RPCCert     string `toml:"rpc-cert"                 comment:"Pinned certificate of the bitcoin RPC endpoint with https, relative to the home directory; the system roots if empty"`
+	RPCCookie   string `toml:"rpccookie"                comment:"Bitcoin .cookie file used instead of rpcuser and rpcpassword, e.g. /root/.bitcoin/signet/.cookie"`
+
+	Nodes  []BitcoinNode `toml:"nodes"                 comment:"Additional bitcoind nodes, the relayer fails over to them if the rpc node is down or lagging"`
+	Quorum int           `toml:"quorum"                comment:"Number of bitcoind nodes that must agree on a block hash before it's relayed, 0 to disable"`
+
+	Backend    string `toml:"backend"                   comment:"Bitcoin data backend: rpc, esplora"`
+	EsploraURL string `toml:"esplora-url"               comment:"Esplora/Electrs REST API, used by the esplora backend, e.g. https://mempool.space/signet/api"`
+
+	BlockSource  string `toml:"block-source"         comment:"Source of new bitcoin blocks: zmq, poll"`
+	PollInterval int    `toml:"poll-interval"        comment:"Interval in seconds of polling the best block, used by the poll block source"`
 
 	ZMQHost string `toml:"zmqhost"                      comment:"Bitcoin ZMQ host"`
 	ZMQPort int    `toml:"zmqport"                      comment:"Bitcoin ZMQ port"`
 
+	MaxReorgDepth int32 `toml:"max-reorg-depth"       comment:"Max depth of a bitcoin reorg to be resolved automatically"`
+
+	MinDeposit         int64          `toml:"min-deposit"          comment:"Min sats of a deposit to a vault, deposits paying less to every vault are skipped and recorded for review, 0 to disable"`
+	MaxDeposit         int64          `toml:"max-deposit"          comment:"Max sats of a deposit to a vault, larger deposits are skipped and recorded for review, 0 to disable"`
+	DepositLimits      []DepositLimit `toml:"deposit-limits"       comment:"Min and max sats of a deposit to a vault address, instead of min-deposit and max-deposit"`
+	DepositScriptTypes []string       `toml:"deposit-script-types" comment:"Script types allowed for the inputs of a deposit considered by deposit-sender: p2pkh, p2sh, p2wpkh, p2wsh, p2tr, all if empty"`
+	DepositSender      string         `toml:"deposit-sender"       comment:"Strategy of resolving the sender of a deposit: first-input, all-inputs (all inputs owned by the same address), op-return (a memo declaring the Side recipient is required in an OP_RETURN output, the owner of the first input is credited)"`
+
 	VaultAddress string `toml:"vault-address"          comment:"Vault address for the transaction"`
 	VaultSigner  bool   `toml:"vault-signer"           comment:"Enable vault signer to sign the transaction, only used for testing"`
 }
 
+type BitcoinNode struct {
+	RPC         string `toml:"rpc"                      comment:"Bitcoin RPC endpoint"`
+	RPCUser     string `toml:"rpcuser"                  comment:"Bitcoin RPC user"`
+	RPCPassword string `toml:"rpcpassword"              comment:"Bitcoin RPC password"`
+	Protocol    string `toml:"protocol"                 comment:"Bitcoin RPC protocol: http, https"`
+	RPCCert     string `toml:"rpc-cert"                 comment:"Pinned certificate of the bitcoin RPC endpoint with https"`
+	RPCCookie   string `toml:"rpccookie"                comment:"Bitcoin .cookie file used instead of rpcuser and rpcpassword"`
+}
+
+type DepositLimit struct {
+	Vault string `toml:"vault"                        comment:"Vault address"`
+	Min   int64  `toml:"min"                          comment:"Min sats of a deposit to the vault, 0 to disable"`
+	Max   int64  `toml:"max"                          comment:"Max sats of a deposit to the vault, 0 to disable"`
+}
+
+// DepositLimit returns the deposit limits of the vault, min-deposit and max-deposit if not configured
+func (b Bitcoin) DepositLimit(vault string) DepositLimit {
+	for _, limit := range b.DepositLimits {
+		if limit.Vault == vault {
+			return limit
+		}
+	}
+	return DepositLimit{Vault: vault, Min: b.MinDeposit, Max: b.MaxDeposit}
+}
+
+// PrimaryNode returns the bitcoind node configured by the rpc fields
+func (b Bitcoin) PrimaryNode() BitcoinNode {
+	return BitcoinNode{
+		RPC:         b.RPC,
+		RPCUser:     b.RPCUser,
+		RPCPassword: b.RPCPassword,
+		Protocol:    b.Protocol,
+		RPCCert:     b.RPCCert,
+		RPCCookie:   b.RPCCookie,
+	}
+}
+
 type Side struct {
 	// Side specific configuration
 	GRPC string `toml:"grpc"                          comment:"Side gRPC endpoint"`
 	RPC  string `toml:"rpc"                           comment:"Side RPC endpoint"`
 	REST string `toml:"rest"                          comment:"Side REST endpoint"`
 
+	GRPCTLS     bool       `toml:"grpc-tls"           comment:"Connect to the Side gRPC endpoint with TLS"`
+	GRPCCAFile  string     `toml:"grpc-ca-file"       comment:"CA certificate of the Side gRPC endpoint, relative to the home directory; rpc.cert if present, the system roots otherwise"`
+	GRPCNodes   []GRPCNode `toml:"grpc-nodes"         comment:"Additional Side gRPC endpoints, the relayer rotates to them when the grpc endpoint fails"`
+	DialTimeout int        `toml:"dial-timeout"       comment:"Timeout in seconds of connecting to a Side gRPC endpoint"`
+
 	Frequency int    `toml:"frequency"                 comment:"frequency of Side block polling in	seconds"`
 	Sender    string `toml:"sender"                    comment:"Side sender address"`
 	ChainID   string `toml:"chain-id"                  comment:"Side chain ID"`
-	Gas       uint64 `toml:"gas"                       comment:"Side chain gas"`
+	Gas       uint64 `toml:"gas,omitempty"             comment:"Deprecated, replaced by max-gas"`
+	MaxGas    uint64 `toml:"max-gas"                   comment:"Max gas limit of a Side transaction, the gas is estimated by simulation"`
+
+	GasAdjustment    float64 `toml:"gas-adjustment"    comment:"Multiplier applied to the simulated gas"`
+	GasPrice         string  `toml:"gas-price"         comment:"Gas price of Side transactions, e.g. 0.001uside"`
+	MinGasPriceQuery bool    `toml:"min-gas-price-query" comment:"Query the minimum gas price of the Side node and use it if higher than the gas price"`
+	MaxFee           string  `toml:"max-fee"           comment:"Max fee of a Side transaction, e.g. 20000uside"`
+
+	WaitForInclusion      bool `toml:"wait-for-inclusion"      comment:"Wait until Side transactions are included in a block, the relayer is blocked meanwhile"`
+	InclusionPollInterval int  `toml:"inclusion-poll-interval" comment:"Interval in seconds between inclusion checks of a Side transaction"`
+	InclusionTimeout      int  `toml:"inclusion-timeout"       comment:"Max seconds to wait for a Side transaction to be included in a block"`
+
+	HeaderBatchSize  int `toml:"header-batch-size"     comment:"Max number of block headers submitted in one transaction"`
+	HeaderBatchBytes int `toml:"header-batch-bytes"    comment:"Max estimated size in bytes of the block headers submitted in one transaction"`
+}
+
+type GRPCNode struct {
+	GRPC   string `toml:"grpc"                        comment:"Side gRPC endpoint"`
+	TLS    bool   `toml:"tls"                         comment:"Connect to the Side gRPC endpoint with TLS"`
+	CAFile string `toml:"ca-file"                     comment:"CA certificate of the Side gRPC endpoint, relative to the home directory"`
 }
 
 func defaultConfig(network string) *Config {
 	return &Config{
 		Global: Global{
-			LogLevel: "info",
+			LogLevel:      "info",
+			LogFormat:     LogFormatAuto,
+			LogMaxSize:    DefaultLogMaxSize,
+			LogMaxBackups: DefaultLogMaxBackups,
+			LogMaxAge:     DefaultLogMaxAge,
+			ReadyMaxLag:   DefaultReadyMaxLag,
+			AdminListen:   DefaultAdminListen,
 		},
 		Bitcoin: Bitcoin{
-			Chain:        network,
-			RPC:          "signet:38332",
-			RPCUser:      "side",
-			RPCPassword:  "12345678",
-			VaultAddress: "",
-			Protocol:     "http",
-			ZMQHost:      "signet",
-			ZMQPort:      38330,
-			VaultSigner:  false,
+			Chain:         network,
+			RPC:           "signet:38332",
+			RPCUser:       "side",
+			RPCPassword:   "12345678",
+			VaultAddress:  "",
+			Protocol:      "http",
+			Backend:       BackendRPC,
+			BlockSource:   BlockSourceZMQ,
+			PollInterval:  DefaultPollInterval,
+			ZMQHost:       "signet",
+			ZMQPort:       38330,
+			MaxReorgDepth: DefaultMaxReorgDepth,
+			DepositSender: DepositSenderFirstInput,
+			VaultSigner:   false,
 		},
 		Side: Side{
-			RPC:       "http://localhost:26657",
-			REST:      "http://localhost:1317",
-			GRPC:      "localhost:9090",
-			Frequency: 6,
-			Sender:    "",
-			ChainID:   "devnet",
-			Gas:       2000000,
+			RPC:         "http://localhost:26657",
+			REST:        "http://localhost:1317",
+			GRPC:        "localhost:9090",
+			DialTimeout: DefaultDialTimeout,
+			Frequency:   6,
+			Sender:      "",
+			ChainID:     "devnet",
+			MaxGas:      DefaultMaxGas,
+
+			GasAdjustment: DefaultGasAdjustment,
+			GasPrice:      DefaultGasPrice,
+			MaxFee:        "20000uside",
+
+			WaitForInclusion:      false,
+			InclusionPollInterval: DefaultInclusionPollInterval,
+			InclusionTimeout:      DefaultInclusionTimeout,
+
+			HeaderBatchSize:  DefaultHeaderBatchSize,
+			HeaderBatchBytes: DefaultHeaderBatchBytes,
 		},
 	}
 }
@@ -84,6 +197,22 @@ func defaultConfig(network string) *Config {
 const (
 	AppName             = "shuttler"
 	InternalKeyringName = "side"
+
+	DefaultMaxReorgDepth         = 10
+	DefaultHeaderBatchSize       = 20
+	DefaultHeaderBatchBytes      = 16 * 1024
+	DefaultGasAdjustment         = 1.5
+	DefaultMaxGas                = 2000000
+	DefaultGasPrice              = "0.001uside"
+	DefaultInclusionTimeout      = 60
+	DefaultInclusionPollInterval = 2
+	DefaultPollInterval          = 10
+	DefaultDialTimeout           = 10
+	DefaultReadyMaxLag           = 2
+	DefaultLogMaxSize            = 100
+	DefaultLogMaxBackups         = 5
+	DefaultLogMaxAge             = 30
+	DefaultAdminListen           = "unix://admin.sock"
 )
 
 var (
@@ -111,7 +240,8 @@ func (c *ConfigBuilder) ConfigFilePath() string {
 	return c.homePath + "/config.toml"
 }
 
-func (c *ConfigBuilder) InitConfig(m, network string) *Config {
+// InitConfig creates the config file and the keyring, and returns the config with the mnemonic of the key
+func (c *ConfigBuilder) InitConfig(m, network string) (*Config, string) {
 	cfg := defaultConfig(network)
 
 	// Set the sender address
@@ -140,11 +270,6 @@ func (c *ConfigBuilder) InitConfig(m, network string) *Config {
 	}
 	cfg.Side.Sender = accAddr.String()
 
-	println("====================================================")
-	println("Mnemonic: ", mnemonic)
-	println("Address:  ", accAddr.String())
-	println("====================================================")
-
 	out, err := toml.Marshal(cfg)
 	if err != nil {
 		panic(err)
@@ -157,7 +282,7 @@ func (c *ConfigBuilder) InitConfig(m, network string) *Config {
 		panic(err)
 	}
 
-	return cfg
+	return cfg, mnemonic
 }
 
 func (c *ConfigBuilder) LoadConfigFile() *Config {
@@ -165,7 +290,14 @@ func (c *ConfigBuilder) LoadConfigFile() *Config {
 	// check if config file exists
 	_, err := os.Stat(c.ConfigFilePath())
 	if os.IsNotExist(err) {
-		return c.InitConfig("", "mainnet")
+		// The generated key must be backed up, the mnemonic is only shown once
+		cfg, mnemonic := c.InitConfig("", "mainnet")
+		fmt.Fprintln(os.Stderr, "====================================================")
+		fmt.Fprintln(os.Stderr, "Mnemonic: ", mnemonic)
+		fmt.Fprintln(os.Stderr, "Address:  ", cfg.Side.Sender)
+		fmt.Fprintln(os.Stderr, "====================================================")
+		fmt.Fprintln(os.Stderr, "\nConfiguration file created at: ", c.ConfigFilePath())
+		return cfg
 	}
 
 	in, err := os.ReadFile(c.ConfigFilePath())
@@ -177,6 +309,10 @@ func (c *ConfigBuilder) LoadConfigFile() *Config {
 	if err != nil {
 		panic(err)
 	}
+	// The gas limit of older configs is the max gas of the estimated gas
+	if cfg.Side.MaxGas == 0 {
+		cfg.Side.MaxGas = cfg.Side.Gas
+	}
 	c.setKeyringPrefix(cfg.Bitcoin.Chain)
 	return cfg
 }
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"slices"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/sideprotocol/shuttler/app/store"
+)
+
+// Script types of the deposit-script-types config
+const (
+	ScriptTypeP2PKH  = "p2pkh"
+	ScriptTypeP2SH   = "p2sh"
+	ScriptTypeP2WPKH = "p2wpkh"
+	ScriptTypeP2WSH  = "p2wsh"
+	ScriptTypeP2TR   = "p2tr"
+)
+
+// Reasons of skipping a deposit by the deposit policy
+const (
+	SkipReasonBelowMinDeposit = "below-min-deposit"
+	SkipReasonAboveMaxDeposit = "above-max-deposit"
+	SkipReasonScriptType      = "script-type"
+)
+
+// Get the script type of the output script
+func scriptType(pkScript []byte) string {
+	switch class := txscript.GetScriptClass(pkScript); class {
+	case txscript.PubKeyHashTy:
+		return ScriptTypeP2PKH
+	case txscript.ScriptHashTy:
+		return ScriptTypeP2SH
+	case txscript.WitnessV0PubKeyHashTy:
+		return ScriptTypeP2WPKH
+	case txscript.WitnessV0ScriptHashTy:
+		return ScriptTypeP2WSH
+	case txscript.WitnessV1TaprootTy:
+		return ScriptTypeP2TR
+	default:
+		return class.String()
+	}
+}
+
+// Check the amounts of the deposit against the limits of the [bitcoin] config
+// The deposit is skipped if it pays less than the min deposit to every vault,
+// or more than the max deposit to any vault, each vault with its own limits.
+func (a *State) checkDepositAmount(tx *btcutil.Tx, outputs []vaultOutput) error {
+	amounts := map[string]int64{}
+	for _, out := range outputs {
+		amounts[out.Vault] += out.Amount
+	}
+	dust := true
+	for vault, amount := range amounts {
+		limit := a.Config.Bitcoin.DepositLimit(vault)
+		if limit.Max > 0 && amount > limit.Max {
+			return skipDeposit(SkipReasonAboveMaxDeposit, "%s: %d sats to %s, max %d", tx.Hash(), amount, vault, limit.Max)
+		}
+		if amount >= limit.Min {
+			dust = false
+		}
+	}
+	if dust {
+		return skipDeposit(SkipReasonBelowMinDeposit, "%s: less than the min deposit to every vault", tx.Hash())
+	}
+	return nil
+}
+
+// Check the script types of the inputs considered by the sender strategy against the [bitcoin] config
+func (a *State) checkDepositScriptTypes(tx *btcutil.Tx, sender *depositSender) error {
+	allowed := a.Config.Bitcoin.DepositScriptTypes
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, t := range sender.ScriptTypes {
+		if !slices.Contains(allowed, t) {
+			return skipDeposit(SkipReasonScriptType, "%s: sender script type %s is not allowed", tx.Hash(), t)
+		}
+	}
+	return nil
+}
+
+// Record the skipped deposit to the ledger of the store, for a later manual review
+func (a *State) recordSkippedDeposit(height int32, tx *btcutil.Tx, skip *DepositSkipError) error {
+	var amount int64
+	for _, out := range a.vaultOutputs(tx) {
+		amount += out.Amount
+	}
+	return a.store.AddSkippedDeposit(&store.SkippedDeposit{
+		Txid:   tx.Hash().String(),
+		Height: height,
+		Amount: amount,
+		Reason: skip.Reason,
+		Detail: skip.Detail,
+		Time:   time.Now().UTC(),
+	})
+}
+
+// SkippedDeposits returns the deposits skipped by the relayer, to be reviewed
+func (a *State) SkippedDeposits() ([]*store.SkippedDeposit, error) {
+	return a.store.SkippedDeposits()
+}
+
+// DismissSkippedDeposit removes the reviewed deposit from the ledger
+func (a *State) DismissSkippedDeposit(txid string) error {
+	return a.store.RemoveSkippedDeposit(txid)
+}
@@ -0,0 +1,246 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"go.uber.org/zap"
+)
+
+// Strategies of resolving the sender of a deposit transaction
+const (
+	// The owner of the first input is the sender
+	DepositSenderFirstInput = "first-input"
+	// All the inputs must be owned by the same address, which is the sender
+	DepositSenderAllInputs = "all-inputs"
+	// The deposit must carry a valid OP_RETURN memo declaring the Side recipient
+	// The btcbridge module still credits the owner of the first input, the memo is logged with the deposit.
+	DepositSenderOpReturn = "op-return"
+)
+
+// Reasons of skipping a deposit transaction
+const (
+	SkipReasonNoInputs        = "no-inputs"
+	SkipReasonPrevTxNotFound  = "prev-tx-not-found"
+	SkipReasonUnknownSender   = "unknown-sender"
+	SkipReasonMultipleSenders = "multiple-senders"
+	SkipReasonNoRecipient     = "no-recipient"
+	SkipReasonInvalidMemo     = "invalid-memo"
+)
+
+// DepositSkipError is returned when the deposit transaction is not accepted by the sender policy
+// The transaction is skipped, and the rest of the block is scanned.
+type DepositSkipError struct {
+	Reason string
+	Detail string
+}
+
+func (e *DepositSkipError) Error() string {
+	return fmt.Sprintf("deposit skipped (%s): %s", e.Reason, e.Detail)
+}
+
+func skipDeposit(reason, format string, args ...any) error {
+	return &DepositSkipError{Reason: reason, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Get the skip error, nil if the deposit is not skipped
+func depositSkip(err error) *DepositSkipError {
+	var skip *DepositSkipError
+	if errors.As(err, &skip) {
+		return skip
+	}
+	return nil
+}
+
+// Sender of a deposit transaction resolved by the sender policy
+type depositSender struct {
+	// Address credited by the btcbridge module, the owner of the first input
+	Address string
+	// Script types of the inputs considered by the strategy
+	ScriptTypes []string
+	// Memo declaring the Side recipient and the routing, if any
+	Memo *Memo
+	// Previous transaction of the first input if it was fetched, submitted with the deposit
+	prevTx *btcutil.Tx
+}
+
+// Owner of an input of a deposit transaction
+type inputOwner struct {
+	Address    string
+	ScriptType string
+	// Previous transaction, nil if the owner was derived from the witness
+	prevTx *btcutil.Tx
+}
+
+// Check the sender strategy of the [bitcoin] config
+// An unknown strategy would fail every deposit, so it's rejected when the config is loaded.
+func validateDepositSender(strategy string) error {
+	switch strategy {
+	case "", DepositSenderFirstInput, DepositSenderAllInputs, DepositSenderOpReturn:
+		return nil
+	}
+	return fmt.Errorf("unknown deposit sender strategy: %s", strategy)
+}
+
+// Get the sender strategy of the [bitcoin] config
+func (a *State) depositSenderStrategy() string {
+	if a.Config.Bitcoin.DepositSender == "" {
+		return DepositSenderFirstInput
+	}
+	return a.Config.Bitcoin.DepositSender
+}
+
+// Resolve the sender of the deposit transaction with the configured strategy
+// The previous transactions are taken from the block when possible,
+// so that the bitcoin node only needs a txindex for the inputs spending older outputs.
+// The deposit message can't carry the recipient or the destination of a memo, the sender is credited in any case.
+func (a *State) resolveDepositSender(tx *btcutil.Tx, txs []*btcutil.Tx) (*depositSender, error) {
+	txIns := tx.MsgTx().TxIn
+	if len(txIns) < 1 {
+		return nil, skipDeposit(SkipReasonNoInputs, "%s", tx.Hash())
+	}
+
+	// An invalid memo is only fatal when the memo is required
+	memo, err := memoFromTx(tx.MsgTx(), a.GetChainCfg())
+	if err != nil {
+		if a.depositSenderStrategy() == DepositSenderOpReturn {
+			return nil, skipDeposit(SkipReasonInvalidMemo, "%s: %v", tx.Hash(), err)
+		}
+		a.Log.Warn("Ignoring the invalid memo of the deposit", zap.String("tx", tx.Hash().String()), zap.Error(err))
+	}
+
+	// Inputs considered by the strategy
+	considered := txIns[:1]
+	switch strategy := a.depositSenderStrategy(); strategy {
+	case DepositSenderFirstInput:
+	case DepositSenderAllInputs:
+		considered = txIns
+	case DepositSenderOpReturn:
+		if memo == nil {
+			return nil, skipDeposit(SkipReasonNoRecipient, "%s", tx.Hash())
+		}
+	default:
+		return nil, fmt.Errorf("unknown deposit sender strategy: %s", strategy)
+	}
+
+	sender := &depositSender{Memo: memo}
+	for i, txIn := range considered {
+		owner, err := a.resolveInputOwner(txIn, txs)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			sender.Address = owner.Address
+			sender.prevTx = owner.prevTx
+		} else if owner.Address != sender.Address {
+			return nil, skipDeposit(SkipReasonMultipleSenders, "%s: inputs owned by %s and %s", tx.Hash(), sender.Address, owner.Address)
+		}
+		sender.ScriptTypes = append(sender.ScriptTypes, owner.ScriptType)
+	}
+
+	if memo != nil && (memo.Recipient != sender.Address || memo.Destination != "") {
+		a.Log.Warn("Deposit memo not supported by the btcbridge module, crediting the sender",
+			zap.String("tx", tx.Hash().String()),
+			zap.String("sender", sender.Address),
+			zap.String("recipient", memo.Recipient),
+			zap.String("destination", memo.Destination),
+		)
+	}
+
+	return sender, nil
+}
+
+// Get the previous transaction of the outpoint, from the block if it's there
+// A previous transaction unknown to the bitcoin node, e.g. without txindex, skips the deposit.
+func (a *State) prevTx(outpoint wire.OutPoint, txs []*btcutil.Tx) (*btcutil.Tx, error) {
+	for _, tx := range txs {
+		if *tx.Hash() == outpoint.Hash {
+			return tx, nil
+		}
+	}
+
+	prevTx, err := a.rpc.GetRawTransaction(&outpoint.Hash)
+	if err != nil {
+		var rpcErr *btcjson.RPCError
+		if errors.As(err, &rpcErr) && rpcErr.Code == btcjson.ErrRPCNoTxInfo {
+			return nil, skipDeposit(SkipReasonPrevTxNotFound, "%s: %s", outpoint.Hash, rpcErr.Message)
+		}
+		return nil, err
+	}
+	return prevTx, nil
+}
+
+// Resolve the owner of the input
+// The owner is derived from the spent output, or from the witness
+// when the previous transaction is unknown to the bitcoin node.
+func (a *State) resolveInputOwner(txIn *wire.TxIn, txs []*btcutil.Tx) (*inputOwner, error) {
+	outpoint := txIn.PreviousOutPoint
+	prevTx, err := a.prevTx(outpoint, txs)
+	if skip := depositSkip(err); skip != nil && skip.Reason == SkipReasonPrevTxNotFound {
+		if addr, ok := witnessAddress(txIn, a.GetChainCfg()); ok {
+			pkScript, err := txscript.PayToAddrScript(addr)
+			if err != nil {
+				return nil, err
+			}
+			return &inputOwner{Address: addr.EncodeAddress(), ScriptType: scriptType(pkScript)}, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prevOuts := prevTx.MsgTx().TxOut
+	if int(outpoint.Index) >= len(prevOuts) {
+		return nil, skipDeposit(SkipReasonUnknownSender, "%s: no output %d", outpoint.Hash, outpoint.Index)
+	}
+	pkScript := prevOuts[outpoint.Index].PkScript
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, a.GetChainCfg())
+	if err != nil || len(addrs) != 1 {
+		return nil, skipDeposit(SkipReasonUnknownSender, "%s: output %d has no single owner", outpoint.Hash, outpoint.Index)
+	}
+	return &inputOwner{Address: addrs[0].EncodeAddress(), ScriptType: scriptType(pkScript), prevTx: prevTx}, nil
+}
+
+// Derive the address of the input from its witness
+// Supports P2WPKH spends, and Taproot script-path spends from the control block.
+// Key-path spends and P2WSH can't be told apart from the witness alone.
+func witnessAddress(txIn *wire.TxIn, chainCfg *chaincfg.Params) (btcutil.Address, bool) {
+	witness := txIn.Witness
+	if len(txIn.SignatureScript) > 0 || len(witness) < 2 {
+		return nil, false
+	}
+
+	// P2WPKH: <signature> <compressed pubkey>
+	if len(witness) == 2 && len(witness[1]) == 33 && (witness[1][0] == 0x02 || witness[1][0] == 0x03) {
+		addr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(witness[1]), chainCfg)
+		if err != nil {
+			return nil, false
+		}
+		return addr, true
+	}
+
+	// Taproot script-path: <inputs...> <script> <control block> [annex]
+	if last := witness[len(witness)-1]; len(last) > 0 && last[0] == txscript.TaprootAnnexTag {
+		witness = witness[:len(witness)-1]
+	}
+	if len(witness) < 2 {
+		return nil, false
+	}
+	ctrlBlock, err := txscript.ParseControlBlock(witness[len(witness)-1])
+	if err != nil {
+		return nil, false
+	}
+	rootHash := ctrlBlock.RootHash(witness[len(witness)-2])
+	outputKey := txscript.ComputeTaprootOutputKey(ctrlBlock.InternalKey, rootHash)
+	addr, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), chainCfg)
+	if err != nil {
+		return nil, false
+	}
+	return addr, true
+}
@@ -0,0 +1,328 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	btcbridge "github.com/sideprotocol/side/x/btcbridge/types"
+	"go.uber.org/zap"
+)
+
+func mockP2WPKH(t *testing.T) (*btcec.PublicKey, btcutil.Address) {
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	pubKey := key.PubKey()
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey.SerializeCompressed()), &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	return pubKey, addr
+}
+
+// App state for the tests, on regtest with a bitcoin node without txindex
+func mockState(t *testing.T) *State {
+	a := NewAppState(t.TempDir())
+	a.Config = defaultConfig("regtest")
+	a.Log = zap.NewNop()
+	a.rpc = &mockBackend{}
+	return a
+}
+
+func Test_WitnessAddress(t *testing.T) {
+
+	// P2WPKH
+	pubKey, addr := mockP2WPKH(t)
+	txIn := &wire.TxIn{Witness: wire.TxWitness{make([]byte, 71), pubKey.SerializeCompressed()}}
+	if got, ok := witnessAddress(txIn, &chaincfg.RegressionNetParams); !ok || got.EncodeAddress() != addr.EncodeAddress() {
+		t.Errorf("Expected %s, got %s", addr.EncodeAddress(), got)
+	}
+
+	// Taproot script-path
+	script := []byte{txscript.OP_TRUE}
+	leaf := txscript.NewBaseTapLeaf(script)
+	tree := txscript.AssembleTaprootScriptTree(leaf)
+	rootHash := tree.RootNode.TapHash()
+	outputKey := txscript.ComputeTaprootOutputKey(pubKey, rootHash[:])
+	taproot, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	ctrlBlock := tree.LeafMerkleProofs[0].ToControlBlock(pubKey)
+	ctrlBytes, err := ctrlBlock.ToBytes()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	txIn = &wire.TxIn{Witness: wire.TxWitness{script, ctrlBytes}}
+	if got, ok := witnessAddress(txIn, &chaincfg.RegressionNetParams); !ok || got.EncodeAddress() != taproot.EncodeAddress() {
+		t.Errorf("Expected %s, got %s", taproot.EncodeAddress(), got)
+	}
+
+	// key-path spends can't be resolved
+	txIn = &wire.TxIn{Witness: wire.TxWitness{make([]byte, 64)}}
+	if _, ok := witnessAddress(txIn, &chaincfg.RegressionNetParams); ok {
+		t.Errorf("Expected no address of a key-path spend")
+	}
+}
+
+func Test_ResolveDepositSender(t *testing.T) {
+
+	a := mockState(t)
+	chainCfg := a.GetChainCfg()
+
+	alicePub, alice := mockP2WPKH(t)
+	bobPub, bob := mockP2WPKH(t)
+	aliceScript, _ := txscript.PayToAddrScript(alice)
+	bobScript, _ := txscript.PayToAddrScript(bob)
+
+	// previous transaction in the same block, paying to alice twice and bob
+	prev := wire.NewMsgTx(2)
+	prev.AddTxOut(wire.NewTxOut(1000, aliceScript))
+	prev.AddTxOut(wire.NewTxOut(1000, aliceScript))
+	prev.AddTxOut(wire.NewTxOut(1000, bobScript))
+	prevTx := btcutil.NewTx(prev)
+	txs := []*btcutil.Tx{prevTx}
+
+	// spend the output of the previous transaction, or of a transaction unknown to the node
+	spend := func(index uint32, witness *btcec.PublicKey) *wire.TxIn {
+		txIn := wire.NewTxIn(wire.NewOutPoint(prevTx.Hash(), index), nil, nil)
+		if witness != nil {
+			txIn.Witness = wire.TxWitness{make([]byte, 71), witness.SerializeCompressed()}
+		}
+		return txIn
+	}
+	spendUnknown := func(witness *btcec.PublicKey) *wire.TxIn {
+		txIn := spend(0, witness)
+		txIn.PreviousOutPoint.Hash = chainhash.Hash{0xff}
+		return txIn
+	}
+	deposit := func(memo []byte, txIns ...*wire.TxIn) *btcutil.Tx {
+		tx := wire.NewMsgTx(2)
+		for _, txIn := range txIns {
+			tx.AddTxIn(txIn)
+		}
+		if memo != nil {
+			script, err := txscript.NullDataScript(memo)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			tx.AddTxOut(wire.NewTxOut(0, script))
+		}
+		return btcutil.NewTx(tx)
+	}
+	memo := func(m *Memo) []byte {
+		data, err := EncodeMemo(m, chainCfg)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		return data
+	}
+
+	cases := []struct {
+		name     string
+		strategy string
+		tx       *btcutil.Tx
+		sender   string
+		prevTx   *btcutil.Tx
+		reason   string
+	}{
+		{"first input", DepositSenderFirstInput, deposit(nil, spend(0, nil), spend(2, nil)), alice.EncodeAddress(), prevTx, ""},
+		{"prevout over witness", DepositSenderFirstInput, deposit(nil, spend(0, bobPub)), alice.EncodeAddress(), prevTx, ""},
+		{"witness without prev tx", DepositSenderFirstInput, deposit(nil, spendUnknown(bobPub)), bob.EncodeAddress(), nil, ""},
+		{"prev tx not found", DepositSenderFirstInput, deposit(nil, spendUnknown(nil)), "", nil, SkipReasonPrevTxNotFound},
+		{"no inputs", DepositSenderFirstInput, deposit(nil), "", nil, SkipReasonNoInputs},
+		{"memo of another recipient", DepositSenderFirstInput, deposit(memo(&Memo{Recipient: bob.EncodeAddress()}), spend(0, nil)), alice.EncodeAddress(), prevTx, ""},
+		{"invalid memo ignored", DepositSenderFirstInput, deposit(append([]byte("SIDE"), 0x02), spend(0, nil)), alice.EncodeAddress(), prevTx, ""},
+		{"same owner", DepositSenderAllInputs, deposit(nil, spend(0, nil), spend(1, nil)), alice.EncodeAddress(), prevTx, ""},
+		{"multiple senders", DepositSenderAllInputs, deposit(nil, spend(0, nil), spend(2, nil)), "", nil, SkipReasonMultipleSenders},
+		{"witness of a later input", DepositSenderAllInputs, deposit(nil, spend(0, nil), spendUnknown(alicePub)), alice.EncodeAddress(), prevTx, ""},
+		{"no memo", DepositSenderOpReturn, deposit(nil, spend(0, nil)), "", nil, SkipReasonNoRecipient},
+		{"memo", DepositSenderOpReturn, deposit(memo(&Memo{Recipient: alice.EncodeAddress(), Referral: "side"}), spend(0, nil), spend(2, nil)), alice.EncodeAddress(), prevTx, ""},
+		{"invalid memo", DepositSenderOpReturn, deposit(append([]byte("SIDE"), 0x02), spend(0, nil)), "", nil, SkipReasonInvalidMemo},
+		{"recipient not owning the first input", DepositSenderOpReturn, deposit(memo(&Memo{Recipient: bob.EncodeAddress()}), spend(0, nil), spend(2, nil)), alice.EncodeAddress(), prevTx, ""},
+		{"destination", DepositSenderOpReturn, deposit(memo(&Memo{Recipient: alice.EncodeAddress(), Destination: "channel-0"}), spend(0, nil)), alice.EncodeAddress(), prevTx, ""},
+	}
+	for _, c := range cases {
+		a.Config.Bitcoin.DepositSender = c.strategy
+		sender, err := a.resolveDepositSender(c.tx, txs)
+		if c.reason != "" {
+			if skip := depositSkip(err); skip == nil || skip.Reason != c.reason {
+				t.Errorf("%s: expected %s, got %v", c.name, c.reason, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if sender.Address != c.sender {
+			t.Errorf("%s: expected sender %s, got %s", c.name, c.sender, sender.Address)
+		}
+		if sender.prevTx != c.prevTx {
+			t.Errorf("%s: unexpected previous transaction %v", c.name, sender.prevTx)
+		}
+	}
+}
+
+func Test_NewDepositMsg(t *testing.T) {
+
+	a := mockState(t)
+	pubKey, _ := mockP2WPKH(t)
+
+	// the previous transaction is unknown to the node, the sender is derived from the witness
+	tx := wire.NewMsgTx(2)
+	txIn := wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{0xff}, 0), nil, nil)
+	txIn.Witness = wire.TxWitness{make([]byte, 71), pubKey.SerializeCompressed()}
+	tx.AddTxIn(txIn)
+	deposit := btcutil.NewTx(tx)
+	txs := []*btcutil.Tx{deposit}
+
+	sender, err := a.resolveDepositSender(deposit, txs)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	msg, err := a.newDepositMsg(&chainhash.Hash{0x01}, deposit, txs, sender)
+	if err != nil {
+		t.Fatalf("Expected the deposit message without the previous transaction, got %v", err)
+	}
+	if msg.PrevTxBytes != "" || msg.TxBytes == "" {
+		t.Errorf("Unexpected deposit message: %+v", msg)
+	}
+}
+
+func Test_ValidateDepositSender(t *testing.T) {
+
+	for strategy, valid := range map[string]bool{
+		"":                      true,
+		DepositSenderFirstInput: true,
+		DepositSenderAllInputs:  true,
+		DepositSenderOpReturn:   true,
+		"last-input":            false,
+	} {
+		if err := validateDepositSender(strategy); (err == nil) != valid {
+			t.Errorf("%q: expected valid %v, got %v", strategy, valid, err)
+		}
+	}
+}
+
+func Test_VaultOutputs(t *testing.T) {
+
+	a := mockState(t)
+
+	_, vault := mockP2WPKH(t)
+	_, other := mockP2WPKH(t)
+	a.params = &btcbridge.Params{Vaults: []*btcbridge.Vault{{Address: vault.EncodeAddress()}}}
+	vaultScript, _ := txscript.PayToAddrScript(vault)
+	otherScript, _ := txscript.PayToAddrScript(other)
+
+	// a deposit paying the vault twice
+	tx := wire.NewMsgTx(2)
+	tx.AddTxOut(wire.NewTxOut(1000, vaultScript))
+	tx.AddTxOut(wire.NewTxOut(500, otherScript))
+	tx.AddTxOut(wire.NewTxOut(2000, vaultScript))
+
+	outputs := a.vaultOutputs(btcutil.NewTx(tx))
+	if len(outputs) != 2 {
+		t.Fatalf("Expected 2 vault outputs, got %d", len(outputs))
+	}
+	if outputs[0].Index != 0 || outputs[0].Amount != 1000 || outputs[1].Index != 2 || outputs[1].Amount != 2000 {
+		t.Errorf("Unexpected vault outputs: %+v", outputs)
+	}
+}
+
+func Test_CheckDepositAmount(t *testing.T) {
+
+	a := mockState(t)
+	a.Config.Bitcoin.MinDeposit = 1000
+	a.Config.Bitcoin.MaxDeposit = 100000
+	a.Config.Bitcoin.DepositLimits = []DepositLimit{{Vault: "b", Min: 5000, Max: 0}}
+	deposit := btcutil.NewTx(wire.NewMsgTx(2))
+
+	cases := []struct {
+		name    string
+		outputs []vaultOutput
+		reason  string
+	}{
+		{"at min", []vaultOutput{{Vault: "a", Amount: 1000}}, ""},
+		{"at max", []vaultOutput{{Vault: "a", Amount: 100000}}, ""},
+		{"above max", []vaultOutput{{Vault: "a", Amount: 100001}}, SkipReasonAboveMaxDeposit},
+		{"dust to every vault", []vaultOutput{{Vault: "a", Amount: 600}, {Vault: "c", Amount: 999}}, SkipReasonBelowMinDeposit},
+		{"outputs to the same vault add up", []vaultOutput{{Vault: "a", Amount: 600}, {Vault: "a", Amount: 600}}, ""},
+		{"below the min of the vault", []vaultOutput{{Vault: "b", Amount: 4999}}, SkipReasonBelowMinDeposit},
+		{"at the min of the vault", []vaultOutput{{Vault: "b", Amount: 5000}}, ""},
+		{"no max of the vault", []vaultOutput{{Vault: "b", Amount: 200000}}, ""},
+	}
+	for _, c := range cases {
+		err := a.checkDepositAmount(deposit, c.outputs)
+		if c.reason == "" && err != nil {
+			t.Errorf("%s: expected the deposit to be accepted, got %v", c.name, err)
+		}
+		if skip := depositSkip(err); c.reason != "" && (skip == nil || skip.Reason != c.reason) {
+			t.Errorf("%s: expected %s, got %v", c.name, c.reason, err)
+		}
+	}
+
+	// no limits by default
+	a.Config = defaultConfig("regtest")
+	if err := a.checkDepositAmount(deposit, []vaultOutput{{Vault: "a", Amount: 1}}); err != nil {
+		t.Errorf("Expected no min deposit by default, got %v", err)
+	}
+}
+
+func Test_CheckDepositScriptTypes(t *testing.T) {
+
+	a := mockState(t)
+
+	// P2PK and P2PKH outputs of the same key have the same owner
+	pubKey, _ := mockP2WPKH(t)
+	p2pk, err := btcutil.NewAddressPubKey(pubKey.SerializeCompressed(), a.GetChainCfg())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	p2pkScript, _ := txscript.PayToAddrScript(p2pk)
+	p2pkhScript, _ := txscript.PayToAddrScript(p2pk.AddressPubKeyHash())
+	prev := wire.NewMsgTx(2)
+	prev.AddTxOut(wire.NewTxOut(1000, p2pkhScript))
+	prev.AddTxOut(wire.NewTxOut(1000, p2pkScript))
+	prevTx := btcutil.NewTx(prev)
+	txs := []*btcutil.Tx{prevTx}
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(prevTx.Hash(), 0), nil, nil))
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(prevTx.Hash(), 1), nil, nil))
+	deposit := btcutil.NewTx(tx)
+
+	cases := []struct {
+		strategy string
+		allowed  []string
+		reason   string
+	}{
+		{DepositSenderFirstInput, nil, ""},
+		{DepositSenderFirstInput, []string{ScriptTypeP2PKH}, ""},
+		{DepositSenderFirstInput, []string{ScriptTypeP2TR}, SkipReasonScriptType},
+		{DepositSenderAllInputs, []string{ScriptTypeP2PKH}, SkipReasonScriptType},
+		{DepositSenderAllInputs, []string{ScriptTypeP2PKH, txscript.PubKeyTy.String()}, ""},
+	}
+	for _, c := range cases {
+		a.Config.Bitcoin.DepositSender = c.strategy
+		a.Config.Bitcoin.DepositScriptTypes = c.allowed
+		sender, err := a.resolveDepositSender(deposit, txs)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		err = a.checkDepositScriptTypes(deposit, sender)
+		if c.reason == "" && err != nil {
+			t.Errorf("%s %v: expected the deposit to be accepted, got %v", c.strategy, c.allowed, err)
+		}
+		if skip := depositSkip(err); c.reason != "" && (skip == nil || skip.Reason != c.reason) {
+			t.Errorf("%s %v: expected %s, got %v", c.strategy, c.allowed, c.reason, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TxErrorKind classifies the failures of Side transactions,
+// so that callers can decide whether to retry, skip or halt.
+type TxErrorKind int
+
+const (
+	// Network failure or timeout, the transaction can be retried as is
+	ErrKindTransient TxErrorKind = iota
+	// The account sequence is out of sync, the transaction can be retried with the corrected sequence
+	ErrKindSequenceMismatch
+	// The fee or gas is too low, retrying won't help until the fee settings are changed
+	ErrKindInsufficientFee
+	// The message has already been submitted, it can be skipped
+	ErrKindDuplicate
+	// The message is invalid or rejected by the btcbridge module, it will never succeed
+	ErrKindPermanent
+)
+
+func (k TxErrorKind) String() string {
+	switch k {
+	case ErrKindTransient:
+		return "transient"
+	case ErrKindSequenceMismatch:
+		return "sequence mismatch"
+	case ErrKindInsufficientFee:
+		return "insufficient fee"
+	case ErrKindDuplicate:
+		return "duplicate"
+	default:
+		return "permanent"
+	}
+}
+
+// Error codes of the sdk codespace
+const (
+	codeWrongSequence    = 32
+	codeInsufficientFee  = 13
+	codeOutOfGas         = 11
+	codeTxInMempoolCache = 19
+)
+
+// TxError is the error of a Side transaction
+type TxError struct {
+	Kind      TxErrorKind
+	Codespace string
+	Code      uint32
+	Err       error
+}
+
+func (e *TxError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("%s error (%s/%d): %v", e.Kind, e.Codespace, e.Code, e.Err)
+	}
+	return fmt.Sprintf("%s error: %v", e.Kind, e.Err)
+}
+
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
+
+// Check if the error is a transaction error of the given kind
+func IsTxErrorKind(err error, kind TxErrorKind) bool {
+	var txErr *TxError
+	return errors.As(err, &txErr) && txErr.Kind == kind
+}
+
+// Classify the failure of a transaction rejected with the given code and log
+func newTxResponseError(codespace string, code uint32, rawLog string) *TxError {
+	kind := classifyLog(rawLog)
+	if codespace == "sdk" {
+		switch code {
+		case codeWrongSequence:
+			kind = ErrKindSequenceMismatch
+		case codeInsufficientFee, codeOutOfGas:
+			kind = ErrKindInsufficientFee
+		case codeTxInMempoolCache:
+			kind = ErrKindDuplicate
+		}
+	}
+	return &TxError{Kind: kind, Codespace: codespace, Code: code, Err: errors.New(rawLog)}
+}
+
+// Classify an error returned by the gRPC services
+// Simulation failures carry the log of the rejected transaction
+func newGRPCError(err error) *TxError {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &TxError{Kind: ErrKindTransient, Err: err}
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Canceled:
+		return &TxError{Kind: ErrKindTransient, Err: err}
+	}
+	return &TxError{Kind: classifyLog(err.Error()), Err: err}
+}
+
+// Classify the failure by the log of the rejected transaction
+func classifyLog(log string) TxErrorKind {
+	switch {
+	case strings.Contains(log, "account sequence mismatch"):
+		return ErrKindSequenceMismatch
+	case strings.Contains(log, "insufficient fee"), strings.Contains(log, "out of gas"):
+		return ErrKindInsufficientFee
+	case strings.Contains(log, "already exist"), strings.Contains(log, "tx already in mempool"):
+		return ErrKindDuplicate
+	default:
+		return ErrKindPermanent
+	}
+}
+
+// Retry the function while it fails with a transient error
+func retryTransient(attempts int, delay time.Duration, fn func() error) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = fn(); !IsTxErrorKind(err, ErrKindTransient) {
+			return err
+		}
+		time.Sleep(delay)
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_ClassifyTxErrors(t *testing.T) {
+
+	cases := []struct {
+		err  error
+		kind TxErrorKind
+	}{
+		{newTxResponseError("sdk", 32, "account sequence mismatch, expected 10, got 9: incorrect account sequence"), ErrKindSequenceMismatch},
+		{newTxResponseError("sdk", 13, "insufficient fees; got: 10uside required: 20uside: insufficient fee"), ErrKindInsufficientFee},
+		{newTxResponseError("sdk", 19, "tx already in mempool"), ErrKindDuplicate},
+		{newTxResponseError("btcbridge", 1106, "block already exists"), ErrKindDuplicate},
+		{newTxResponseError("btcbridge", 1100, "invalid block header"), ErrKindPermanent},
+		{newGRPCError(status.Error(codes.Unavailable, "connection refused")), ErrKindTransient},
+		{newGRPCError(fmt.Errorf("failed to broadcast tx: %w", status.Error(codes.DeadlineExceeded, "timeout"))), ErrKindTransient},
+		{newGRPCError(status.Error(codes.Unknown, "account sequence mismatch, expected 3, got 2")), ErrKindSequenceMismatch},
+	}
+
+	for i, c := range cases {
+		if !IsTxErrorKind(c.err, c.kind) {
+			t.Errorf("%d: Expected %s, got %v", i, c.kind, c.err)
+		}
+	}
+
+	// wrapped errors keep their kind
+	err := fmt.Errorf("failed to submit deposit: %w", cases[2].err)
+	if !IsTxErrorKind(err, ErrKindDuplicate) {
+		t.Errorf("Expected %s, got %v", ErrKindDuplicate, err)
+	}
+
+	seq, ok := expectedSequence(cases[0].err)
+	if !ok || seq != 10 {
+		t.Errorf("Expected sequence 10, got %d", seq)
+	}
+}
@@ -0,0 +1,190 @@
+package app
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// EsploraClient reads the bitcoin network from an Esplora/Electrs REST API
+// It doesn't require a full node with txindex.
+type EsploraClient struct {
+	url    string
+	client *http.Client
+}
+
+var _ BitcoinBackend = (*EsploraClient)(nil)
+
+// Block of the Esplora API
+type esploraBlock struct {
+	ID                string  `json:"id"`
+	Height            int32   `json:"height"`
+	Version           int32   `json:"version"`
+	Timestamp         int64   `json:"timestamp"`
+	Bits              uint32  `json:"bits"`
+	Nonce             uint64  `json:"nonce"`
+	Difficulty        float64 `json:"difficulty"`
+	MerkleRoot        string  `json:"merkle_root"`
+	PreviousBlockHash string  `json:"previousblockhash"`
+}
+
+// Transaction of the Esplora API
+type esploraTx struct {
+	TxID   string `json:"txid"`
+	Status struct {
+		Confirmed   bool   `json:"confirmed"`
+		BlockHeight int32  `json:"block_height"`
+		BlockHash   string `json:"block_hash"`
+		BlockTime   int64  `json:"block_time"`
+	} `json:"status"`
+}
+
+// NewEsploraClient creates a client of the Esplora API at the given url, e.g. https://mempool.space/signet/api
+func NewEsploraClient(url string) *EsploraClient {
+	return &EsploraClient{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// Send the request and return the body of a successful response
+func (e *EsploraClient) do(method, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, e.url+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	bz, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("esplora %s %s: %s: %s", method, path, res.Status, strings.TrimSpace(string(bz)))
+	}
+	return bz, nil
+}
+
+func (e *EsploraClient) getHash(path string) (*chainhash.Hash, error) {
+	bz, err := e.do(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return chainhash.NewHashFromStr(strings.TrimSpace(string(bz)))
+}
+
+func (e *EsploraClient) GetBestBlockHash() (*chainhash.Hash, error) {
+	return e.getHash("/blocks/tip/hash")
+}
+
+func (e *EsploraClient) GetBlockHash(blockHeight int64) (*chainhash.Hash, error) {
+	return e.getHash(fmt.Sprintf("/block-height/%d", blockHeight))
+}
+
+func (e *EsploraClient) GetBlockHeaderVerbose(blockHash *chainhash.Hash) (*btcjson.GetBlockHeaderVerboseResult, error) {
+	bz, err := e.do(http.MethodGet, "/block/"+blockHash.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	block := &esploraBlock{}
+	if err := json.Unmarshal(bz, block); err != nil {
+		return nil, err
+	}
+
+	bz, err = e.do(http.MethodGet, "/blocks/tip/height", nil)
+	if err != nil {
+		return nil, err
+	}
+	tip, err := strconv.ParseInt(strings.TrimSpace(string(bz)), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &btcjson.GetBlockHeaderVerboseResult{
+		Hash:          block.ID,
+		Confirmations: tip - int64(block.Height) + 1,
+		Height:        block.Height,
+		Version:       block.Version,
+		VersionHex:    fmt.Sprintf("%08x", block.Version),
+		MerkleRoot:    block.MerkleRoot,
+		Time:          block.Timestamp,
+		Nonce:         block.Nonce,
+		Bits:          fmt.Sprintf("%08x", block.Bits),
+		Difficulty:    block.Difficulty,
+		PreviousHash:  block.PreviousBlockHash,
+	}, nil
+}
+
+func (e *EsploraClient) GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error) {
+	bz, err := e.do(http.MethodGet, "/block/"+blockHash.String()+"/raw", nil)
+	if err != nil {
+		return nil, err
+	}
+	block := &wire.MsgBlock{}
+	if err := block.Deserialize(bytes.NewReader(bz)); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+func (e *EsploraClient) GetRawTransaction(txHash *chainhash.Hash) (*btcutil.Tx, error) {
+	bz, err := e.do(http.MethodGet, "/tx/"+txHash.String()+"/raw", nil)
+	if err != nil {
+		return nil, err
+	}
+	return btcutil.NewTxFromBytes(bz)
+}
+
+// GetRawTransactionVerbose returns the transaction with the block it's included in, if confirmed
+// Only the txid, hex, block hash and block time are set.
+func (e *EsploraClient) GetRawTransactionVerbose(txHash *chainhash.Hash) (*btcjson.TxRawResult, error) {
+	bz, err := e.do(http.MethodGet, "/tx/"+txHash.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	tx := &esploraTx{}
+	if err := json.Unmarshal(bz, tx); err != nil {
+		return nil, err
+	}
+
+	rawHex, err := e.do(http.MethodGet, "/tx/"+txHash.String()+"/hex", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &btcjson.TxRawResult{Txid: tx.TxID, Hex: strings.TrimSpace(string(rawHex))}
+	if tx.Status.Confirmed {
+		result.BlockHash = tx.Status.BlockHash
+		result.Blocktime = tx.Status.BlockTime
+	}
+	return result, nil
+}
+
+// SendRawTransaction broadcasts the transaction, the fee is checked by the Esplora node
+func (e *EsploraClient) SendRawTransaction(tx *wire.MsgTx, _ bool) (*chainhash.Hash, error) {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return nil, err
+	}
+
+	bz, err := e.do(http.MethodPost, "/tx", strings.NewReader(hex.EncodeToString(buf.Bytes())))
+	if err != nil {
+		return nil, err
+	}
+	return chainhash.NewHashFromStr(strings.TrimSpace(string(bz)))
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_EsploraClient(t *testing.T) {
+
+	block := chaincfg.SigNetParams.GenesisBlock
+	hash := block.BlockHash()
+	tx := block.Transactions[0]
+	txhash := tx.TxHash()
+
+	var blockBuf, txBuf bytes.Buffer
+	require.NoError(t, block.Serialize(&blockBuf))
+	require.NoError(t, tx.Serialize(&txBuf))
+
+	broadcasted := ""
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blocks/tip/hash", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, hash.String())
+	})
+	mux.HandleFunc("/blocks/tip/height", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "9")
+	})
+	mux.HandleFunc("/block-height/0", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, hash.String())
+	})
+	mux.HandleFunc("/block/"+hash.String(), func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id":"%s","height":0,"version":1,"timestamp":%d,"bits":%d,"nonce":%d,"merkle_root":"%s","previousblockhash":null}`,
+			hash.String(), block.Header.Timestamp.Unix(), block.Header.Bits, block.Header.Nonce, block.Header.MerkleRoot.String())
+	})
+	mux.HandleFunc("/block/"+hash.String()+"/raw", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(blockBuf.Bytes())
+	})
+	mux.HandleFunc("/tx/"+txhash.String()+"/raw", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(txBuf.Bytes())
+	})
+	mux.HandleFunc("/tx", func(w http.ResponseWriter, r *http.Request) {
+		bz, _ := io.ReadAll(r.Body)
+		broadcasted = string(bz)
+		fmt.Fprint(w, txhash.String())
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewEsploraClient(server.URL + "/")
+
+	best, err := client.GetBestBlockHash()
+	require.NoError(t, err)
+	require.Equal(t, hash, *best)
+
+	h, err := client.GetBlockHash(0)
+	require.NoError(t, err)
+	require.Equal(t, hash, *h)
+
+	header, err := client.GetBlockHeaderVerbose(&hash)
+	require.NoError(t, err)
+	require.Equal(t, hash.String(), header.Hash)
+	require.Equal(t, int64(10), header.Confirmations)
+	require.Equal(t, "1e0377ae", header.Bits)
+	require.Equal(t, block.Header.MerkleRoot.String(), header.MerkleRoot)
+	require.Equal(t, block.Header.Timestamp, time.Unix(header.Time, 0))
+
+	b, err := client.GetBlock(&hash)
+	require.NoError(t, err)
+	require.Equal(t, hash, b.BlockHash())
+
+	rawTx, err := client.GetRawTransaction(&txhash)
+	require.NoError(t, err)
+	require.Equal(t, txhash, *rawTx.Hash())
+
+	sent, err := client.SendRawTransaction(tx, false)
+	require.NoError(t, err)
+	require.Equal(t, txhash, *sent)
+	require.Equal(t, hex.EncodeToString(txBuf.Bytes()), broadcasted)
+
+	// errors of the api are returned
+	_, err = client.GetBlockHash(1)
+	require.Error(t, err)
+}
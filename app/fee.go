@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/node"
+	"github.com/cosmos/cosmos-sdk/client/tx"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	txtypes "github.com/cosmos/cosmos-sdk/types/tx"
+	"go.uber.org/zap"
+)
+
+// Estimate the gas of the messages by simulation
+// The factory's gas adjustment is applied to the simulated gas
+func (a *State) estimateGas(txf tx.Factory, msgs ...sdk.Msg) (uint64, error) {
+
+	txBytes, err := txf.BuildSimTx(msgs...)
+	if err != nil {
+		return 0, &TxError{Kind: ErrKindPermanent, Err: fmt.Errorf("failed to build simulation tx: %w", err)}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	res, err := a.txServiceClient.Simulate(ctx, &txtypes.SimulateRequest{
+		TxBytes: txBytes,
+	})
+	if err != nil {
+		return 0, newGRPCError(fmt.Errorf("failed to simulate tx: %w", err))
+	}
+
+	gas := uint64(txf.GasAdjustment() * float64(res.GasInfo.GasUsed))
+	a.Log.Debug("Estimated gas", zap.Uint64("used", res.GasInfo.GasUsed), zap.Uint64("gas", gas))
+
+	// Make sure a runaway estimate is not broadcasted
+	if a.Config.Side.MaxGas > 0 && gas > a.Config.Side.MaxGas {
+		return 0, &TxError{Kind: ErrKindPermanent, Err: fmt.Errorf("estimated gas %d exceeds the max gas %d", gas, a.Config.Side.MaxGas)}
+	}
+
+	return gas, nil
+}
+
+// Calculate the fee of the gas from the gas price
+// The minimum gas price of the node is used if it's higher than the configured one
+// Returns an error if the fee exceeds the max fee
+func (a *State) calculateFee(gas uint64) (sdk.Coin, error) {
+
+	gasPrice, err := a.gasPrice()
+	if err != nil {
+		return sdk.Coin{}, err
+	}
+
+	if a.Config.Side.MinGasPriceQuery {
+		minGasPrice, err := a.queryMinGasPrice(gasPrice.Denom)
+		if err != nil {
+			a.Log.Warn("Failed to query the minimum gas price", zap.Error(err))
+		} else if gasPrice.Amount.LT(minGasPrice) {
+			gasPrice.Amount = minGasPrice
+		}
+	}
+
+	fee := sdk.NewCoin(gasPrice.Denom, gasPrice.Amount.MulInt64(int64(gas)).Ceil().RoundInt())
+
+	if a.Config.Side.MaxFee != "" {
+		maxFee, err := sdk.ParseCoinNormalized(a.Config.Side.MaxFee)
+		if err != nil {
+			return sdk.Coin{}, fmt.Errorf("invalid max fee %s: %w", a.Config.Side.MaxFee, err)
+		}
+		if maxFee.Denom != fee.Denom {
+			return sdk.Coin{}, fmt.Errorf("max fee denom %s does not match the gas price denom %s", maxFee.Denom, fee.Denom)
+		}
+		if maxFee.IsLT(fee) {
+			return sdk.Coin{}, fmt.Errorf("fee %s exceeds the max fee %s", fee, maxFee)
+		}
+	}
+
+	return fee, nil
+}
+
+// Parse the configured gas price, the default gas price is used if not set
+func (a *State) gasPrice() (sdk.DecCoin, error) {
+	price := a.Config.Side.GasPrice
+	if price == "" {
+		price = DefaultGasPrice
+	}
+	gasPrice, err := sdk.ParseDecCoin(price)
+	if err != nil {
+		return sdk.DecCoin{}, fmt.Errorf("invalid gas price %s: %w", price, err)
+	}
+	return gasPrice, nil
+}
+
+// Query the minimum gas price of the Side node in the given denom
+func (a *State) queryMinGasPrice(denom string) (sdk.Dec, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	res, err := node.NewServiceClient(a.gRPC).Config(ctx, &node.ConfigRequest{})
+	if err != nil {
+		return sdk.Dec{}, err
+	}
+
+	prices, err := sdk.ParseDecCoins(res.MinimumGasPrice)
+	if err != nil {
+		return sdk.Dec{}, err
+	}
+	return prices.AmountOf(denom), nil
+}
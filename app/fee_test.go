@@ -0,0 +1,59 @@
+package app
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func Test_CalculateFee(t *testing.T) {
+
+	a := &State{Config: defaultConfig("mainnet"), Log: zap.NewNop()}
+
+	fee, err := a.calculateFee(150000)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if fee.String() != "150uside" {
+		t.Errorf("Expected 150uside, got %s", fee.String())
+	}
+
+	// the fee is rounded up
+	fee, err = a.calculateFee(1001)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if fee.String() != "2uside" {
+		t.Errorf("Expected 2uside, got %s", fee.String())
+	}
+
+	// the fee is capped
+	if _, err = a.calculateFee(30000000); err == nil {
+		t.Errorf("Expected the fee to exceed the max fee")
+	}
+
+	a.Config.Side.MaxFee = "1000000uatom"
+	if _, err = a.calculateFee(150000); err == nil {
+		t.Errorf("Expected a denom mismatch")
+	}
+}
+
+func Test_GasPrice(t *testing.T) {
+
+	a := &State{Config: defaultConfig("mainnet"), Log: zap.NewNop()}
+
+	// the default gas price is used if not set
+	a.Config.Side.GasPrice = ""
+	price, err := a.gasPrice()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if price.Denom != "uside" {
+		t.Errorf("Expected the default gas price, got %s", price.String())
+	}
+
+	a.Config.Side.GasPrice = "uside"
+	if _, err = a.gasPrice(); err == nil {
+		t.Errorf("Expected an invalid gas price")
+	}
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// Response of the health endpoints
+type healthResponse struct {
+	Status            string `json:"status"`
+	Reason            string `json:"reason,omitempty"`
+	BitcoinHeight     int32  `json:"bitcoin_height,omitempty"`
+	LightClientHeight uint64 `json:"light_client_height,omitempty"`
+	Synced            bool   `json:"synced"`
+}
+
+// Query the best block of the bitcoin node
+func (a *State) queryBitcoinTip() (*btcjson.GetBlockHeaderVerboseResult, error) {
+	hash, err := a.rpc.GetBestBlockHash()
+	if err != nil {
+		return nil, err
+	}
+	return a.rpc.GetBlockHeaderVerbose(hash)
+}
+
+func (a *State) readyMaxLag() int32 {
+	if a.Config.Global.ReadyMaxLag <= 0 {
+		return DefaultReadyMaxLag
+	}
+	return a.Config.Global.ReadyMaxLag
+}
+
+// Check the process is up and both the bitcoin and Side backends are reachable
+func (a *State) checkHealth() *healthResponse {
+	res := &healthResponse{Synced: a.isSynced()}
+
+	tip, err := a.queryBitcoinTip()
+	if err != nil {
+		res.Reason = fmt.Sprintf("bitcoin backend unreachable: %v", err)
+		return res
+	}
+	res.BitcoinHeight = tip.Height
+
+	lightClientTip, err := a.QueryChainTip()
+	if err != nil {
+		res.Reason = fmt.Sprintf("side backend unreachable: %v", err)
+		return res
+	}
+	res.LightClientHeight = lightClientTip.Height
+
+	res.Status = "ok"
+	return res
+}
+
+// Check the relayer is healthy, synced, not halted, close to the bitcoin tip and authorized
+func (a *State) checkReady() *healthResponse {
+	res := a.checkHealth()
+	if res.Status != "ok" {
+		return res
+	}
+	res.Status = ""
+
+	if err := a.halted(); err != nil {
+		res.Reason = fmt.Sprintf("header relay halted: %v", err)
+		return res
+	}
+	if !res.Synced {
+		res.Reason = "light client not synced"
+		return res
+	}
+	if lag := res.BitcoinHeight - int32(res.LightClientHeight); lag > a.readyMaxLag() {
+		res.Reason = fmt.Sprintf("light client is %d blocks behind the bitcoin tip", lag)
+		return res
+	}
+
+	params, err := a.QueryParams()
+	if err != nil {
+		res.Reason = fmt.Sprintf("failed to query the light client params: %v", err)
+		return res
+	}
+	if !params.IsAuthorizedSender(a.Config.Side.Sender) {
+		res.Reason = fmt.Sprintf("%s is not an authorized relayer", a.Config.Side.Sender)
+		return res
+	}
+
+	res.Status = "ok"
+	return res
+}
+
+func writeHealth(w http.ResponseWriter, res *healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if res.Status != "ok" {
+		res.Status = "fail"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (a *State) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, a.checkHealth())
+}
+
+func (a *State) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, a.checkReady())
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	btcbridge "github.com/sideprotocol/side/x/btcbridge/types"
+)
+
+func Test_HealthEndpoints(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		down     bool
+		lcHeight int
+		synced   bool
+		halted   bool
+		relayer  string
+		healthz  int
+		readyz   int
+	}{
+		{"ready", false, 10, true, false, "side1relayer", http.StatusOK, http.StatusOK},
+		{"within the max lag", false, 8, true, false, "side1relayer", http.StatusOK, http.StatusOK},
+		{"bitcoin down", true, 10, true, false, "side1relayer", http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		{"not synced", false, 10, false, false, "side1relayer", http.StatusOK, http.StatusServiceUnavailable},
+		{"halted", false, 10, true, true, "side1relayer", http.StatusOK, http.StatusServiceUnavailable},
+		{"lagging", false, 7, true, false, "side1relayer", http.StatusOK, http.StatusServiceUnavailable},
+		{"not authorized", false, 10, true, false, "side1other", http.StatusOK, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		a := mockState(t)
+		a.Config.Side.Sender = "side1relayer"
+		a.rpc = &mockBackend{hashes: mockChain(11, 0), down: tt.down}
+		a.grpcQueryClient = &mockQueryClient{
+			headers: mockHeaders(mockChain(tt.lcHeight+1, 0)),
+			params:  btcbridge.Params{AuthorizedRelayers: []string{tt.relayer}},
+		}
+		a.setSynced(tt.synced)
+		if tt.halted {
+			a.halt(errors.New("reorg too deep"))
+		}
+
+		for _, c := range []struct {
+			path    string
+			handler http.HandlerFunc
+			code    int
+		}{
+			{"/healthz", a.handleHealthz, tt.healthz},
+			{"/readyz", a.handleReadyz, tt.readyz},
+		} {
+			rec := httptest.NewRecorder()
+			c.handler(rec, httptest.NewRequest(http.MethodGet, c.path, nil))
+
+			res := &healthResponse{}
+			if err := json.NewDecoder(rec.Body).Decode(res); err != nil {
+				t.Fatalf("%s %s: %v", tt.name, c.path, err)
+			}
+			if rec.Code != c.code {
+				t.Errorf("%s %s: expected %d, got %d (%s)", tt.name, c.path, c.code, rec.Code, res.Reason)
+			}
+			if (rec.Code == http.StatusOK) != (res.Status == "ok") || (res.Status != "ok") == (res.Reason == "") {
+				t.Errorf("%s %s: unexpected response %+v", tt.name, c.path, res)
+			}
+		}
+	}
+}
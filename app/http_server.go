@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+// StartHTTPServers serves the metrics and health endpoints on the listeners of the [global] section
+// Endpoints on the same listener share one server. The servers are shut down when the context is done.
+func (a *State) StartHTTPServers(ctx context.Context) {
+	muxes := map[string]*http.ServeMux{}
+	handle := func(listen, pattern string, handler http.Handler) {
+		if listen == "" {
+			return
+		}
+		if muxes[listen] == nil {
+			muxes[listen] = http.NewServeMux()
+		}
+		muxes[listen].Handle(pattern, handler)
+	}
+
+	handle(a.Config.Global.MetricsListen, "/metrics", promhttp.Handler())
+	handle(a.Config.Global.HealthListen, "/healthz", http.HandlerFunc(a.handleHealthz))
+	handle(a.Config.Global.HealthListen, "/readyz", http.HandlerFunc(a.handleReadyz))
+
+	for listen, mux := range muxes {
+		ln, err := net.Listen("tcp", listen)
+		if err != nil {
+			a.Log.Error("Failed to listen", zap.String("listen", listen), zap.Error(err))
+			continue
+		}
+		a.serveHTTP(ctx, ln, mux)
+	}
+}
+
+// Serve the handler on the listener until the context is done
+func (a *State) serveHTTP(ctx context.Context, ln net.Listener, handler http.Handler) {
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: DefaultTimeout}
+	listen := ln.Addr().String()
+
+	go func() {
+		a.Log.Info("Serving HTTP", zap.String("listen", listen))
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.Log.Error("HTTP server failed", zap.String("listen", listen), zap.Error(err))
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+}
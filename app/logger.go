@@ -0,0 +1,122 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	zaplogfmt "github.com/jsternberg/zap-logfmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/term"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Log formats of the log-format flag and config
+const (
+	LogFormatAuto    = "auto"
+	LogFormatLogfmt  = "logfmt"
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+)
+
+// LogOptions configure the logger of the application
+type LogOptions struct {
+	Level  string
+	Format string
+	// Rotated log file, in addition to stderr, disabled if empty
+	File       string
+	MaxSize    int
+	MaxBackups int
+	MaxAge     int
+}
+
+// Create the log encoder of the format
+// The auto format is console output on a terminal, logfmt otherwise.
+func newLogEncoder(format string, terminal bool) (zapcore.Encoder, error) {
+	config := zap.NewProductionEncoderConfig()
+	config.EncodeTime = zapcore.RFC3339TimeEncoder
+
+	if format == "" || format == LogFormatAuto {
+		format = LogFormatLogfmt
+		if terminal {
+			format = LogFormatConsole
+		}
+	}
+
+	switch format {
+	case LogFormatJSON:
+		return zapcore.NewJSONEncoder(config), nil
+	case LogFormatLogfmt:
+		return zaplogfmt.NewEncoder(config), nil
+	case LogFormatConsole:
+		config.EncodeLevel = zapcore.CapitalLevelEncoder
+		if terminal {
+			config.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+		return zapcore.NewConsoleEncoder(config), nil
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", format)
+	}
+}
+
+// NewLogger creates a logger writing to stderr, and to the rotated log file if configured
+func NewLogger(opts LogOptions) (*zap.Logger, error) {
+	level := zapcore.InfoLevel
+	if opts.Level != "" {
+		l, err := zapcore.ParseLevel(opts.Level)
+		if err != nil {
+			return nil, err
+		}
+		level = l
+	}
+
+	encoder, err := newLogEncoder(opts.Format, term.IsTerminal(int(os.Stderr.Fd())))
+	if err != nil {
+		return nil, err
+	}
+	cores := []zapcore.Core{zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), level)}
+
+	if opts.File != "" {
+		// The log file is never a terminal
+		fileEncoder, err := newLogEncoder(opts.Format, false)
+		if err != nil {
+			return nil, err
+		}
+		file := &lumberjack.Logger{
+			Filename:   opts.File,
+			MaxSize:    opts.MaxSize,
+			MaxBackups: opts.MaxBackups,
+			MaxAge:     opts.MaxAge,
+		}
+		cores = append(cores, zapcore.NewCore(fileEncoder, zapcore.AddSync(file), level))
+	}
+
+	return zap.New(zapcore.NewTee(cores...), zap.AddCaller()), nil
+}
+
+// Get the log options from the flags and the [global] config
+// The log-level and log-format flags take precedence over the config, and --debug over both.
+func (a *State) logOptions() LogOptions {
+	global := a.Config.Global
+	opts := LogOptions{
+		Level:      global.LogLevel,
+		Format:     global.LogFormat,
+		MaxSize:    global.LogMaxSize,
+		MaxBackups: global.LogMaxBackups,
+		MaxAge:     global.LogMaxAge,
+	}
+
+	if level := a.Viper.GetString("log-level"); level != "" {
+		opts.Level = level
+	}
+	if a.Viper.IsSet("log-format") {
+		opts.Format = a.Viper.GetString("log-format")
+	}
+	if a.Debug {
+		opts.Level = zapcore.DebugLevel.String()
+	}
+	if global.LogFile != "" {
+		opts.File = a.homeFile(global.LogFile)
+	}
+	return opts
+}
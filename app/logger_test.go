@@ -0,0 +1,41 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_NewLogger(t *testing.T) {
+
+	for _, format := range []string{"", LogFormatAuto, LogFormatLogfmt, LogFormatJSON, LogFormatConsole} {
+		if _, err := newLogEncoder(format, false); err != nil {
+			t.Errorf("Expected format %q to be supported, got %v", format, err)
+		}
+	}
+	if _, err := newLogEncoder("xml", false); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+	if _, err := NewLogger(LogOptions{Level: "verbose"}); err == nil {
+		t.Errorf("Expected an error for an unknown level")
+	}
+
+	// JSON lines are written to the log file
+	path := filepath.Join(t.TempDir(), "logs", "shuttler.log")
+	log, err := NewLogger(LogOptions{Level: "info", Format: LogFormatJSON, File: path, MaxSize: 1})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	log.Debug("hidden")
+	log.Info("relayed")
+	log.Sync()
+
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !strings.Contains(string(out), `"msg":"relayed"`) || strings.Contains(string(out), "hidden") {
+		t.Errorf("Unexpected log file content: %s", out)
+	}
+}
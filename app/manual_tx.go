@@ -0,0 +1,136 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/sideprotocol/shuttler/app/store"
+	btcbridge "github.com/sideprotocol/side/x/btcbridge/types"
+)
+
+// Confirmed bitcoin transaction with the block it's included in
+type confirmedTx struct {
+	blockHash  *chainhash.Hash
+	merkleRoot chainhash.Hash
+	tx         *btcutil.Tx
+	txs        []*btcutil.Tx
+}
+
+// Locate the transaction in its block
+// The block is looked up from the bitcoin backend if the block hash is not given,
+// and it must have been relayed to the light client, whose header gives the merkle root.
+func (a *State) locateTx(txid, blockHash string) (*confirmedTx, error) {
+	txHash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid txid: %w", err)
+	}
+
+	if blockHash == "" {
+		res, err := a.rpc.GetRawTransactionVerbose(txHash)
+		if err != nil {
+			return nil, err
+		}
+		if res.BlockHash == "" {
+			return nil, fmt.Errorf("transaction %s is not confirmed", txid)
+		}
+		blockHash = res.BlockHash
+	}
+
+	hash, err := chainhash.NewHashFromStr(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block hash: %w", err)
+	}
+
+	header, err := a.QueryBlockHeaderByHash(hash.String())
+	if err != nil {
+		return nil, fmt.Errorf("block %s is not relayed to the light client: %w", hash, err)
+	}
+	merkleRoot, err := chainhash.NewHashFromStr(header.MerkleRoot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid merkle root of the light client header %s: %w", hash, err)
+	}
+
+	block, err := a.rpc.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	if block.Header.MerkleRoot != *merkleRoot {
+		return nil, fmt.Errorf("block %s of the bitcoin node doesn't match the light client header", hash)
+	}
+
+	located := &confirmedTx{blockHash: hash, merkleRoot: *merkleRoot}
+	for _, t := range block.Transactions {
+		tx := btcutil.NewTx(t)
+		located.txs = append(located.txs, tx)
+		if tx.Hash().IsEqual(txHash) {
+			located.tx = tx
+		}
+	}
+	if located.tx == nil {
+		return nil, fmt.Errorf("transaction %s is not in block %s", txid, hash)
+	}
+	return located, nil
+}
+
+// Verify the proof of inclusion against the merkle root of the light client header
+func (c *confirmedTx) verifyProof(proof []string) error {
+	if !VerifyMerkleProof(proof, c.tx.Hash(), &c.merkleRoot) {
+		return fmt.Errorf("invalid merkle proof of transaction %s in block %s", c.tx.Hash(), c.blockHash)
+	}
+	return nil
+}
+
+// BuildDepositMsg builds the deposit message of a confirmed transaction paying to a vault, with the proof verified locally
+// It's used to submit a deposit missed by the relayer.
+func (a *State) BuildDepositMsg(txid, blockHash string) (*btcbridge.MsgSubmitDepositTransactionRequest, error) {
+	params, err := a.QueryParams()
+	if err != nil {
+		return nil, err
+	}
+	a.params = params
+
+	located, err := a.locateTx(txid, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if kind, _ := a.vaultTxKind(located.tx); kind != store.Deposit {
+		return nil, fmt.Errorf("transaction %s is not a deposit, it pays no vault or spends from a vault", txid)
+	}
+
+	sender, err := a.resolveDepositSender(located.tx, located.txs)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := a.newDepositMsg(located.blockHash, located.tx, located.txs, sender)
+	if err != nil {
+		return nil, err
+	}
+	if err := located.verifyProof(msg.Proof); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// BuildWithdrawalMsg builds the withdrawal message of a confirmed transaction spending from a vault,
+// with the proof verified locally. It's used to close a withdrawal missed by the relayer.
+func (a *State) BuildWithdrawalMsg(txid, blockHash string) (*btcbridge.MsgSubmitWithdrawTransactionRequest, error) {
+	params, err := a.QueryParams()
+	if err != nil {
+		return nil, err
+	}
+
+	located, err := a.locateTx(txid, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if withdrawalVault(params.Vaults, located.tx) == nil {
+		return nil, fmt.Errorf("transaction %s does not spend from a vault", txid)
+	}
+
+	msg := a.newWithdrawalMsg(located.blockHash, located.tx, located.txs)
+	if err := located.verifyProof(msg.Proof); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
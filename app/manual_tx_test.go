@@ -0,0 +1,90 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	btcbridge "github.com/sideprotocol/side/x/btcbridge/types"
+)
+
+func Test_BuildWithdrawalMsg(t *testing.T) {
+
+	a := mockState(t)
+	a.Config.Side.Sender = "side1relayer"
+
+	vaultPubKey, vault := mockP2WPKH(t)
+	vaultScript, _ := txscript.PayToAddrScript(vault)
+
+	// coinbase, a withdrawal spending from the vault and a deposit to the vault
+	coinbase := wire.NewMsgTx(2)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), nil, nil))
+	coinbase.AddTxOut(wire.NewTxOut(5000, []byte{txscript.OP_TRUE}))
+	withdrawal := wire.NewMsgTx(2)
+	withdrawal.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{0x01}, 0), nil, wire.TxWitness{make([]byte, 71), vaultPubKey.SerializeCompressed()}))
+	withdrawal.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+	deposit := wire.NewMsgTx(2)
+	deposit.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{0x02}, 0), nil, nil))
+	deposit.AddTxOut(wire.NewTxOut(1000, vaultScript))
+
+	block := &wire.MsgBlock{Transactions: []*wire.MsgTx{coinbase, withdrawal, deposit}}
+	txs := []*btcutil.Tx{btcutil.NewTx(coinbase), btcutil.NewTx(withdrawal), btcutil.NewTx(deposit)}
+	tree := blockchain.BuildMerkleTreeStore(txs, false)
+	block.Header.MerkleRoot = *tree[len(tree)-1]
+	blockHash := block.BlockHash()
+
+	// a block of the bitcoin node that doesn't match its light client header
+	forged := &wire.MsgBlock{Header: wire.BlockHeader{Nonce: 1}, Transactions: block.Transactions}
+	forgedHash := forged.BlockHash()
+
+	a.rpc = &mockBackend{blocks: map[chainhash.Hash]*wire.MsgBlock{blockHash: block, forgedHash: forged}}
+	a.grpcQueryClient = &mockQueryClient{
+		headers: []*btcbridge.BlockHeader{
+			{Hash: blockHash.String(), Height: 100, MerkleRoot: block.Header.MerkleRoot.String()},
+			{Hash: forgedHash.String(), Height: 101, MerkleRoot: block.Header.MerkleRoot.String()},
+		},
+		params: btcbridge.Params{Vaults: []*btcbridge.Vault{{Address: vault.EncodeAddress(), PubKey: hex.EncodeToString(vaultPubKey.SerializeCompressed())}}},
+	}
+
+	msg, err := a.BuildWithdrawalMsg(withdrawal.TxHash().String(), blockHash.String())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if msg.Sender != "side1relayer" || msg.Blockhash != blockHash.String() {
+		t.Errorf("Unexpected message %+v", msg)
+	}
+	txBytes, err := base64.StdEncoding.DecodeString(msg.TxBytes)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	tx, err := btcutil.NewTxFromBytes(txBytes)
+	if err != nil || tx.MsgTx().TxHash() != withdrawal.TxHash() {
+		t.Errorf("Expected the withdrawal transaction, got %v", err)
+	}
+	txid := withdrawal.TxHash()
+	if !VerifyMerkleProof(msg.Proof, &txid, &block.Header.MerkleRoot) {
+		t.Errorf("Expected a valid merkle proof")
+	}
+
+	cases := []struct {
+		name      string
+		txid      string
+		blockHash string
+	}{
+		{"not spending from a vault", deposit.TxHash().String(), blockHash.String()},
+		{"not in the block", chainhash.Hash{0x03}.String(), blockHash.String()},
+		{"not relayed", withdrawal.TxHash().String(), chainhash.Hash{0x04}.String()},
+		{"not matching the light client", withdrawal.TxHash().String(), forgedHash.String()},
+		{"invalid txid", "txid", blockHash.String()},
+	}
+	for _, c := range cases {
+		if _, err := a.BuildWithdrawalMsg(c.txid, c.blockHash); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+}
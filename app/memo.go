@@ -0,0 +1,227 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Memo of a deposit transaction, carried in an OP_RETURN output
+//
+// The payload is the magic "SIDE", the version 0x01, the recipient as
+// <witness version> <program length> <program>, then optional fields as
+// <tag> <length> <value>: 0x01 the destination chain ID or IBC channel, 0x02 the referral tag.
+// The recipient is a Side address, i.e. a segwit address of the bitcoin network of the relayer,
+// carried as its witness program and given the bech32 prefix of the network when decoded.
+// The memo is logged with the deposit, the btcbridge module credits the sender, see resolveDepositSender.
+type Memo struct {
+	Recipient   string `json:"recipient"`
+	Destination string `json:"destination,omitempty"`
+	Referral    string `json:"referral,omitempty"`
+}
+
+const (
+	memoVersion = 0x01
+
+	memoTagDestination = 0x01
+	memoTagReferral    = 0x02
+)
+
+var (
+	memoMagic = []byte("SIDE")
+
+	// Chain ID or IBC channel, e.g. osmosis-1 or channel-0
+	memoDestinationRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,32}$`)
+	memoReferralRegexp    = regexp.MustCompile(`^[\x21-\x7e]{1,16}$`)
+)
+
+// Segwit address of a Side account
+type segwitAddress interface {
+	btcutil.Address
+	WitnessVersion() byte
+	WitnessProgram() []byte
+}
+
+// Bech32 prefix of the Side addresses, the segwit prefix of the bitcoin network
+func sideAddressPrefix(chainCfg *chaincfg.Params) string {
+	return chainCfg.Bech32HRPSegwit
+}
+
+// Parse the Side address of the bitcoin network
+// Side accounts use the bech32 prefix of the bitcoin network set in setKeyringPrefix.
+func parseSideAddress(address string, chainCfg *chaincfg.Params) (segwitAddress, error) {
+	addr, err := btcutil.DecodeAddress(address, chainCfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Side address %s: %w", address, err)
+	}
+	witness, ok := addr.(segwitAddress)
+	if !ok || !addr.IsForNet(chainCfg) {
+		return nil, fmt.Errorf("invalid Side address %s: not a %s1 address", address, sideAddressPrefix(chainCfg))
+	}
+	return witness, nil
+}
+
+// Validate the memo for the bitcoin network
+func (m *Memo) Validate(chainCfg *chaincfg.Params) error {
+	if m.Recipient == "" {
+		return errors.New("memo has no recipient")
+	}
+	if _, err := parseSideAddress(m.Recipient, chainCfg); err != nil {
+		return err
+	}
+	if m.Destination != "" && !memoDestinationRegexp.MatchString(m.Destination) {
+		return fmt.Errorf("invalid memo destination: %q", m.Destination)
+	}
+	if m.Referral != "" && !memoReferralRegexp.MatchString(m.Referral) {
+		return fmt.Errorf("invalid memo referral: %q", m.Referral)
+	}
+	return nil
+}
+
+// EncodeMemo encodes the memo into the OP_RETURN payload
+func EncodeMemo(m *Memo, chainCfg *chaincfg.Params) ([]byte, error) {
+	if err := m.Validate(chainCfg); err != nil {
+		return nil, err
+	}
+	recipient, _ := parseSideAddress(m.Recipient, chainCfg)
+	program := recipient.WitnessProgram()
+
+	var buf bytes.Buffer
+	buf.Write(memoMagic)
+	buf.WriteByte(memoVersion)
+	buf.WriteByte(recipient.WitnessVersion())
+	buf.WriteByte(byte(len(program)))
+	buf.Write(program)
+	for _, field := range []struct {
+		tag   byte
+		value string
+	}{
+		{memoTagDestination, m.Destination},
+		{memoTagReferral, m.Referral},
+	} {
+		if field.value == "" {
+			continue
+		}
+		buf.WriteByte(field.tag)
+		buf.WriteByte(byte(len(field.value)))
+		buf.WriteString(field.value)
+	}
+
+	if buf.Len() > txscript.MaxDataCarrierSize {
+		return nil, fmt.Errorf("memo of %d bytes exceeds the OP_RETURN limit of %d bytes", buf.Len(), txscript.MaxDataCarrierSize)
+	}
+	return buf.Bytes(), nil
+}
+
+// Check if the OP_RETURN payload is a memo
+func isMemo(data []byte) bool {
+	return bytes.HasPrefix(data, memoMagic)
+}
+
+// DecodeMemo decodes and validates the memo of the OP_RETURN payload
+func DecodeMemo(data []byte, chainCfg *chaincfg.Params) (*Memo, error) {
+	if !isMemo(data) {
+		return nil, errors.New("not a memo")
+	}
+	r := bytes.NewReader(data[len(memoMagic):])
+
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, errors.New("memo has no version")
+	}
+	if version != memoVersion {
+		return nil, fmt.Errorf("unsupported memo version: %d", version)
+	}
+
+	// <tag or witness version> <length> <value>
+	readField := func() (byte, []byte, error) {
+		tag, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, errors.New("truncated memo")
+		}
+		value := make([]byte, length)
+		if n, _ := r.Read(value); n != int(length) {
+			return 0, nil, errors.New("truncated memo")
+		}
+		return tag, value, nil
+	}
+
+	witnessVersion, program, err := readField()
+	if err != nil {
+		return nil, errors.New("memo has no recipient")
+	}
+	recipient, err := newWitnessAddress(witnessVersion, program, chainCfg)
+	if err != nil {
+		return nil, err
+	}
+	memo := &Memo{Recipient: recipient.EncodeAddress()}
+
+	for r.Len() > 0 {
+		tag, value, err := readField()
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case memoTagDestination:
+			memo.Destination = string(value)
+		case memoTagReferral:
+			memo.Referral = string(value)
+		default:
+			return nil, fmt.Errorf("unknown memo field: %d", tag)
+		}
+	}
+
+	if err := memo.Validate(chainCfg); err != nil {
+		return nil, err
+	}
+	return memo, nil
+}
+
+// Create the segwit address of the witness program
+func newWitnessAddress(version byte, program []byte, chainCfg *chaincfg.Params) (btcutil.Address, error) {
+	switch {
+	case version == 0 && len(program) == 20:
+		return btcutil.NewAddressWitnessPubKeyHash(program, chainCfg)
+	case version == 0 && len(program) == 32:
+		return btcutil.NewAddressWitnessScriptHash(program, chainCfg)
+	case version == 1 && len(program) == 32:
+		return btcutil.NewAddressTaproot(program, chainCfg)
+	default:
+		return nil, fmt.Errorf("invalid memo recipient: witness version %d, program of %d bytes", version, len(program))
+	}
+}
+
+// MemoScript builds the OP_RETURN output script of the memo
+func MemoScript(m *Memo, chainCfg *chaincfg.Params) ([]byte, error) {
+	data, err := EncodeMemo(m, chainCfg)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.NullDataScript(data)
+}
+
+// Get the memo of the transaction, from the first OP_RETURN output carrying a memo
+// The OP_RETURN outputs of other protocols are ignored.
+func memoFromTx(tx *wire.MsgTx, chainCfg *chaincfg.Params) (*Memo, error) {
+	for _, txOut := range tx.TxOut {
+		if !txscript.IsNullData(txOut.PkScript) {
+			continue
+		}
+		data, err := txscript.PushedData(txOut.PkScript)
+		if err != nil || len(data) != 1 || !isMemo(data[0]) {
+			continue
+		}
+		return DecodeMemo(data[0], chainCfg)
+	}
+	return nil, nil
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func Test_Memo(t *testing.T) {
+
+	chainCfg := &chaincfg.RegressionNetParams
+	_, recipient := mockP2WPKH(t)
+
+	memo := &Memo{Recipient: recipient.EncodeAddress(), Destination: "channel-0", Referral: "side"}
+	data, err := EncodeMemo(memo, chainCfg)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	decoded, err := DecodeMemo(data, chainCfg)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if *decoded != *memo {
+		t.Errorf("Expected %+v, got %+v", memo, decoded)
+	}
+
+	// the recipient must be a Side address of the network
+	if _, err := EncodeMemo(memo, &chaincfg.MainNetParams); err == nil {
+		t.Errorf("Expected an error encoding a regtest recipient on mainnet")
+	}
+	if _, err := EncodeMemo(&Memo{Recipient: "mjSk1Ny9spzU2fouzYgLqGUD8U41iR35QN"}, &chaincfg.TestNet3Params); err == nil {
+		t.Errorf("Expected an error encoding a legacy recipient")
+	}
+
+	// the memo must fit in an OP_RETURN output
+	if _, err := EncodeMemo(&Memo{Recipient: memo.Recipient, Destination: strings.Repeat("a", 32), Referral: strings.Repeat("b", 16)}, chainCfg); err != nil {
+		t.Errorf("Expected the max fields to fit, got %v", err)
+	}
+
+	// truncated memo
+	if _, err := DecodeMemo(data[:len(data)-1], chainCfg); err == nil {
+		t.Errorf("Expected an error decoding a truncated memo")
+	}
+
+	// other OP_RETURN outputs are ignored
+	other, _ := txscript.NullDataScript([]byte("other protocol"))
+	script, err := MemoScript(memo, chainCfg)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	tx := wire.NewMsgTx(2)
+	tx.AddTxOut(wire.NewTxOut(0, other))
+	if m, err := memoFromTx(tx, chainCfg); m != nil || err != nil {
+		t.Errorf("Expected no memo, got %+v, %v", m, err)
+	}
+	tx.AddTxOut(wire.NewTxOut(0, script))
+	if m, err := memoFromTx(tx, chainCfg); err != nil || m == nil || *m != *memo {
+		t.Errorf("Expected %+v, got %+v, %v", memo, m, err)
+	}
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+const metricsNamespace = AppName
+
+// Metrics of the relayer, exposed on the metrics listener of the [global] section
+var (
+	bitcoinTipHeight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "bitcoin_tip_height",
+		Help:      "Height of the best block of the bitcoin node",
+	})
+	lightClientTipHeight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "light_client_tip_height",
+		Help:      "Height of the light client tip on the sidechain",
+	})
+	headerLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "header_lag",
+		Help:      "Number of bitcoin blocks the light client is behind",
+	})
+	headersSubmitted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "headers_submitted_total",
+		Help:      "Number of bitcoin block headers submitted to the sidechain",
+	})
+	vaultTxSubmitted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "vault_tx_submitted_total",
+		Help:      "Number of vault transactions submitted to the sidechain, by kind",
+	}, []string{"kind"})
+	vaultTxFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "vault_tx_failed_total",
+		Help:      "Number of vault transactions failed to be submitted to the sidechain, by kind",
+	}, []string{"kind"})
+	depositAmount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "deposit_amount_sats_total",
+		Help:      "Amount in sats of the deposit outputs submitted to the sidechain, by vault",
+	}, []string{"vault"})
+	depositsSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "deposits_skipped_total",
+		Help:      "Number of deposit transactions skipped by the sender policy, by reason",
+	}, []string{"reason"})
+	sideTxFees = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "side_tx_fees_total",
+		Help:      "Fees of the Side transactions broadcasted by the relayer, by denom",
+	}, []string{"denom"})
+	accountBalance = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "account_balance",
+		Help:      "Balance of the relayer account, by denom",
+	}, []string{"denom"})
+	signingRequestsPending = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "signing_requests_pending",
+		Help:      "Number of withdrawal signing requests, by signing status",
+	}, []string{"status"})
+	zmqMessagesReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "zmq_messages_received_total",
+		Help:      "Number of ZMQ block notifications received from bitcoind",
+	})
+)
+
+// Refresh the number of signing requests of every status
+// All the statuses are queried before any is set, so that a failed query
+// keeps the previous counts instead of a partial or empty gauge.
+func (a *State) refreshSigningRequestsPending() {
+	counts, err := a.countSigningRequests()
+	if err != nil {
+		a.Log.Debug("Failed to query the signing requests", zap.Error(err))
+		return
+	}
+	for status, count := range counts {
+		signingRequestsPending.WithLabelValues(status).Set(float64(count))
+	}
+}
+
+// Add the fee of a broadcasted Side transaction
+func addSideTxFee(fee sdk.Coin) {
+	sideTxFees.WithLabelValues(fee.Denom).Add(float64(fee.Amount.Int64()))
+}
+
+// UpdateMetrics refreshes the metrics that are not updated by the relayer events:
+// the tips of bitcoin and the light client, the signing requests, and the balance of the relayer account
+func (a *State) UpdateMetrics() {
+	if a.Config.Global.MetricsListen == "" {
+		return
+	}
+
+	var btcHeight, lcHeight int32
+	if tip, err := a.queryBitcoinTip(); err == nil {
+		btcHeight = tip.Height
+		bitcoinTipHeight.Set(float64(btcHeight))
+	}
+
+	if tip, err := a.QueryChainTip(); err == nil {
+		lcHeight = int32(tip.Height)
+		lightClientTipHeight.Set(float64(lcHeight))
+	}
+
+	if btcHeight > 0 && lcHeight > 0 {
+		headerLag.Set(float64(btcHeight - lcHeight))
+	}
+
+	a.refreshSigningRequestsPending()
+
+	balance, err := a.queryBalance()
+	if err != nil {
+		a.Log.Debug("Failed to query the account balance", zap.Error(err))
+		return
+	}
+	if balance != nil {
+		accountBalance.WithLabelValues(balance.Denom).Set(float64(balance.Amount.Int64()))
+	}
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	btcbridge "github.com/sideprotocol/side/x/btcbridge/types"
+)
+
+func Test_RefreshSigningRequestsPending(t *testing.T) {
+
+	a := mockState(t)
+	client := &mockQueryClient{requests: []*btcbridge.BitcoinSigningRequest{
+		{Txid: "a", Status: btcbridge.SigningStatus_SIGNING_STATUS_CREATED},
+		{Txid: "b", Status: btcbridge.SigningStatus_SIGNING_STATUS_BROADCASTED},
+	}}
+	a.grpcQueryClient = client
+
+	// a stale count of a status
+	signingRequestsPending.WithLabelValues(btcbridge.SigningStatus_SIGNING_STATUS_REJECTED.String()).Set(3)
+
+	expected := []struct {
+		status btcbridge.SigningStatus
+		count  float64
+	}{
+		{btcbridge.SigningStatus_SIGNING_STATUS_CREATED, 1},
+		{btcbridge.SigningStatus_SIGNING_STATUS_SIGNED, 0},
+		{btcbridge.SigningStatus_SIGNING_STATUS_BROADCASTED, 1},
+		{btcbridge.SigningStatus_SIGNING_STATUS_CONFIRMED, 0},
+		{btcbridge.SigningStatus_SIGNING_STATUS_REJECTED, 0},
+	}
+	check := func(when string) {
+		for _, c := range expected {
+			if got := testutil.ToFloat64(signingRequestsPending.WithLabelValues(c.status.String())); got != c.count {
+				t.Errorf("%s: expected %v requests %s, got %v", when, c.count, c.status, got)
+			}
+		}
+		if n := testutil.CollectAndCount(signingRequestsPending); n != 5 {
+			t.Errorf("%s: expected the 5 statuses, got %d", when, n)
+		}
+	}
+
+	a.refreshSigningRequestsPending()
+	check("refreshed")
+
+	// the previous counts are kept when the query fails
+	client.err = errors.New("unavailable")
+	a.refreshSigningRequestsPending()
+	check("failed query")
+}
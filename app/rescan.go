@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/sideprotocol/shuttler/app/store"
+	btcbridge "github.com/sideprotocol/side/x/btcbridge/types"
+	"go.uber.org/zap"
+)
+
+// Results of the vault transactions found by a scan
+const (
+	ScanResultSubmitted = "submitted"
+	// Already known by the relayer or the btcbridge module
+	ScanResultKnown    = "known"
+	ScanResultRejected = "rejected"
+	// Not accepted by the deposit sender policy
+	ScanResultSkipped = "skipped"
+)
+
+// ScannedTx is a vault transaction found by a scan
+type ScannedTx struct {
+	Kind   store.TxKind `json:"kind"`
+	Height int32        `json:"height"`
+	Txid   string       `json:"txid"`
+	Result string       `json:"result"`
+	Reason string       `json:"reason,omitempty"`
+}
+
+// ScanReport collects the vault transactions found by a scan
+type ScanReport struct {
+	From int32       `json:"from"`
+	To   int32       `json:"to"`
+	Txs  []ScannedTx `json:"txs"`
+}
+
+func (r *ScanReport) add(kind store.TxKind, height int32, txid, result string) {
+	if r == nil {
+		return
+	}
+	r.Txs = append(r.Txs, ScannedTx{Kind: kind, Height: height, Txid: txid, Result: result})
+}
+
+func (r *ScanReport) skip(kind store.TxKind, height int32, txid, reason string) {
+	if r == nil {
+		return
+	}
+	r.Txs = append(r.Txs, ScannedTx{Kind: kind, Height: height, Txid: txid, Result: ScanResultSkipped, Reason: reason})
+}
+
+// Count the transactions with the result
+func (r *ScanReport) Count(result string) int {
+	n := 0
+	for _, tx := range r.Txs {
+		if tx.Result == result {
+			n++
+		}
+	}
+	return n
+}
+
+// Rescan walks the confirmed blocks in the height range for vault transactions,
+// waiting delay between blocks. Transactions already known by the relayer or the btcbridge module
+// are skipped, the btcbridge module is queried for each of them before submitting. The progress is recorded in the store, so that an interrupted rescan can be resumed,
+// and the report is returned with the heights actually scanned, also on error.
+func (a *State) Rescan(from, to int32, delay time.Duration) (*ScanReport, error) {
+	if from <= 0 || to < from {
+		return nil, fmt.Errorf("invalid rescan range: %d to %d", from, to)
+	}
+
+	params, err := a.QueryParams()
+	if err != nil {
+		return nil, err
+	}
+	a.params = params
+
+	// Only the blocks confirmed on the light client are scanned
+	tip, err := a.QueryChainTip()
+	if err != nil {
+		return nil, err
+	}
+	confirmed := confirmedHeight(int32(tip.Height), a.params.Confirmations)
+	if to > confirmed {
+		a.Log.Warn("Rescan limited to the confirmed blocks", zap.Int32("to", to), zap.Int32("confirmed", confirmed))
+		to = confirmed
+	}
+	if to < from {
+		return nil, fmt.Errorf("no confirmed blocks from %d, the last confirmed block is %d", from, confirmed)
+	}
+
+	report := &ScanReport{From: from, To: from - 1}
+	for h := from; h <= to; h++ {
+		if err := a.scanBlock(h, report); err != nil {
+			return report, fmt.Errorf("failed to scan block %d: %w", h, err)
+		}
+		if err := a.store.SetRescanHeight(h); err != nil {
+			return report, err
+		}
+		report.To = h
+		a.Log.Info("Block rescanned", zap.Int32("height", h), zap.Int32("to", to))
+
+		if delay > 0 && h < to {
+			time.Sleep(delay)
+		}
+	}
+	return report, nil
+}
+
+// RescanHeight returns the last height of the previous rescan, to resume it
+func (a *State) RescanHeight() (int32, error) {
+	return a.store.RescanHeight()
+}
+
+// Check if the btcbridge module already knows the vault transaction
+// A withdrawal is known once its signing request is confirmed, and a deposit while its vault outputs are unspent.
+// A deposit whose outputs were spent since is still submitted, and rejected as a duplicate.
+func (a *State) knownByBridge(kind store.TxKind, tx *btcutil.Tx) (bool, error) {
+	// Timeout context for our queries
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	txid := tx.Hash().String()
+	switch kind {
+	case store.Withdrawal:
+		res, err := a.grpcQueryClient.QuerySigningRequest(ctx, &btcbridge.QuerySigningRequestRequest{
+			Status: btcbridge.SigningStatus_SIGNING_STATUS_CONFIRMED,
+		})
+		if err != nil {
+			return false, err
+		}
+		for _, request := range res.Requests {
+			if request.Txid == txid {
+				return true, nil
+			}
+		}
+
+	case store.Deposit:
+		queried := map[string]bool{}
+		for _, out := range a.vaultOutputs(tx) {
+			if queried[out.Vault] {
+				continue
+			}
+			queried[out.Vault] = true
+
+			res, err := a.grpcQueryClient.QueryUTXOsByAddress(ctx, &btcbridge.QueryUTXOsByAddressRequest{Address: out.Vault})
+			if err != nil {
+				return false, err
+			}
+			for _, utxo := range res.Utxos {
+				if utxo.Txid == txid {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"regexp"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Max attempts to send a transaction when the account sequence mismatches
+	maxSequenceRetries = 3
+
+	// Max transactions kept as pending, the transactions are only removed on inclusion with wait-for-inclusion
+	maxPendingTxs = 100
+)
+
+var expectedSequenceRegexp = regexp.MustCompile(`account sequence mismatch, expected (\d+)`)
+
+// Account sequence of the relayer
+// Transactions accepted by the mempool are pending until they are included in a block,
+// so the sequence is advanced locally instead of being queried for every transaction.
+type sequenceManager struct {
+	// Held while a transaction is signed and broadcasted
+	sync.Mutex
+
+	loaded bool
+	next   uint64
+	// Pending transactions by sequence
+	pending map[uint64]string
+}
+
+// Parse the sequence expected by the sidechain from the error
+func expectedSequence(err error) (uint64, bool) {
+	m := expectedSequenceRegexp.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(m[1], 10, 64)
+	return seq, err == nil
+}
+
+// Reload the account from the sidechain
+// Pending transactions below the committed sequence have been included in a block
+func (a *State) refreshAccount() error {
+	account, err := a.queryAccountInfo()
+	if err != nil {
+		return err
+	}
+
+	a.seq.next = account.Sequence
+	a.seq.loaded = true
+	for seq := range a.seq.pending {
+		if seq < account.Sequence {
+			delete(a.seq.pending, seq)
+		}
+	}
+
+	a.Log.Debug("Account loaded", zap.Uint64("sequence", account.Sequence), zap.Int("pending", len(a.seq.pending)))
+	return nil
+}
+
+// Record the transaction accepted by the mempool and advance the sequence
+// Only the last maxPendingTxs transactions are kept, the older ones have been included or dropped long ago.
+func (a *State) commitSequence(seq uint64, txhash string) {
+	if a.seq.pending == nil {
+		a.seq.pending = map[uint64]string{}
+	}
+	a.seq.pending[seq] = txhash
+	a.seq.next = seq + 1
+
+	for s := range a.seq.pending {
+		if s+maxPendingTxs <= seq {
+			delete(a.seq.pending, s)
+		}
+	}
+}
+
+// Correct the sequence after a mismatch
+// The expected sequence is taken from the error if present,
+// otherwise the account is queried again before the next attempt.
+func (a *State) recoverSequence(err error) {
+	if seq, ok := expectedSequence(err); ok {
+		a.seq.next = seq
+		return
+	}
+	a.seq.loaded = false
+}
+
+// Remove the transaction included in a block from the pending ones
+func (a *State) removePending(txhash string) {
+	a.seq.Lock()
+	defer a.seq.Unlock()
+
+	for seq, hash := range a.seq.pending {
+		if hash == txhash {
+			delete(a.seq.pending, seq)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	txtypes "github.com/cosmos/cosmos-sdk/types/tx"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_RecoverSequence(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		err    error
+		next   uint64
+		loaded bool
+	}{
+		{"expected sequence", newTxResponseError("sdk", 32, "account sequence mismatch, expected 10, got 9: incorrect account sequence"), 10, true},
+		{"unknown sequence", errors.New("account sequence mismatch"), 9, false},
+	}
+	for _, tt := range tests {
+		a := &State{Log: zap.NewNop()}
+		a.seq.loaded = true
+		a.seq.next = 9
+
+		a.recoverSequence(tt.err)
+		if a.seq.next != tt.next || a.seq.loaded != tt.loaded {
+			t.Errorf("%s: expected sequence %d loaded %v, got %d %v", tt.name, tt.next, tt.loaded, a.seq.next, a.seq.loaded)
+		}
+	}
+}
+
+func Test_BroadcastWithSequence(t *testing.T) {
+
+	a := &State{Log: zap.NewNop()}
+	a.seq.loaded = true
+	a.seq.next = 5
+
+	// retried with the sequence expected by the sidechain
+	sequences := []uint64{}
+	res, err := a.broadcastWithSequence(func(sequence uint64) (*sdk.TxResponse, error) {
+		sequences = append(sequences, sequence)
+		if sequence != 7 {
+			return nil, newTxResponseError("sdk", 32, "account sequence mismatch, expected 7, got 5: incorrect account sequence")
+		}
+		a.commitSequence(sequence, "ABCD")
+		return &sdk.TxResponse{TxHash: "ABCD"}, nil
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if res.TxHash != "ABCD" || len(sequences) != 2 || sequences[0] != 5 || sequences[1] != 7 {
+		t.Errorf("Expected the sequences 5 and 7, got %v", sequences)
+	}
+	if a.seq.next != 8 || a.seq.pending[7] != "ABCD" {
+		t.Errorf("Expected the next sequence 8 and the pending transaction, got %d %v", a.seq.next, a.seq.pending)
+	}
+
+	// gives up after the max retries
+	attempts := 0
+	_, err = a.broadcastWithSequence(func(sequence uint64) (*sdk.TxResponse, error) {
+		attempts++
+		return nil, newTxResponseError("sdk", 32, "account sequence mismatch, expected 20, got 8: incorrect account sequence")
+	})
+	if !IsTxErrorKind(err, ErrKindSequenceMismatch) || attempts != maxSequenceRetries {
+		t.Errorf("Expected %d attempts and a sequence mismatch, got %d, %v", maxSequenceRetries, attempts, err)
+	}
+
+	// other errors are not retried
+	attempts = 0
+	_, err = a.broadcastWithSequence(func(sequence uint64) (*sdk.TxResponse, error) {
+		attempts++
+		return nil, newTxResponseError("sdk", 13, "insufficient fee")
+	})
+	if !IsTxErrorKind(err, ErrKindInsufficientFee) || attempts != 1 {
+		t.Errorf("Expected a single attempt, got %d, %v", attempts, err)
+	}
+}
+
+func Test_CommitSequence(t *testing.T) {
+
+	a := &State{Log: zap.NewNop()}
+	for seq := uint64(0); seq < 150; seq++ {
+		a.commitSequence(seq, "ABCD")
+	}
+	if len(a.seq.pending) != maxPendingTxs {
+		t.Errorf("Expected %d pending transactions, got %d", maxPendingTxs, len(a.seq.pending))
+	}
+	if _, ok := a.seq.pending[150-maxPendingTxs]; !ok {
+		t.Errorf("Expected the last %d transactions to be kept", maxPendingTxs)
+	}
+	if a.seq.next != 150 {
+		t.Errorf("Expected the next sequence 150, got %d", a.seq.next)
+	}
+}
+
+type mockTxServiceClient struct {
+	txtypes.ServiceClient
+	res *sdk.TxResponse
+}
+
+func (m *mockTxServiceClient) GetTx(ctx context.Context, in *txtypes.GetTxRequest, opts ...grpc.CallOption) (*txtypes.GetTxResponse, error) {
+	if m.res == nil {
+		return nil, status.Error(codes.NotFound, "tx not found")
+	}
+	return &txtypes.GetTxResponse{TxResponse: m.res}, nil
+}
+
+func Test_WaitForInclusion(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		res      *sdk.TxResponse
+		included bool
+		kind     TxErrorKind
+	}{
+		{"included", &sdk.TxResponse{TxHash: "ABCD", Height: 100}, true, 0},
+		{"failed in DeliverTx", &sdk.TxResponse{TxHash: "ABCD", Height: 100, Codespace: "btcbridge", Code: 2, RawLog: "invalid block header"}, false, ErrKindPermanent},
+		{"not included", nil, false, ErrKindTransient},
+	}
+	for _, tt := range tests {
+		a := &State{Log: zap.NewNop(), Config: defaultConfig("regtest")}
+		a.Config.Side.InclusionPollInterval = 1
+		a.Config.Side.InclusionTimeout = 1
+		a.txServiceClient = &mockTxServiceClient{res: tt.res}
+		a.commitSequence(7, "ABCD")
+
+		res, err := a.waitForInclusion("ABCD")
+		if tt.included {
+			if err != nil || res.Height != 100 {
+				t.Errorf("%s: expected the included transaction, got %v %v", tt.name, res, err)
+			}
+		} else if !IsTxErrorKind(err, tt.kind) {
+			t.Errorf("%s: expected a %s error, got %v", tt.name, tt.kind, err)
+		}
+
+		// the pending transaction is only removed on inclusion
+		if _, pending := a.seq.pending[7]; pending != (tt.res == nil) {
+			t.Errorf("%s: unexpected pending transactions %v", tt.name, a.seq.pending)
+		}
+	}
+}
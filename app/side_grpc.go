@@ -0,0 +1,200 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// Side gRPC endpoint of the pool
+type grpcEndpoint struct {
+	address string
+	conn    *grpc.ClientConn
+}
+
+// Pool of Side gRPC endpoints
+// Calls go to the current endpoint, and rotate to the next endpoint when it's unreachable.
+// It's used in place of a single *grpc.ClientConn by the Side clients.
+type grpcPool struct {
+	sync.Mutex
+	log       *zap.Logger
+	endpoints []grpcEndpoint
+	current   int
+}
+
+var _ grpc.ClientConnInterface = (*grpcPool)(nil)
+
+// Check if the Side gRPC call failed because of the endpoint, rather than the request
+func isEndpointError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// Check if the request has not reached the endpoint, so it's safe to retry on another one
+func isRetryableEndpointError(err error) bool {
+	return status.Code(err) == codes.Unavailable
+}
+
+// Get the transport credentials of the endpoint
+// The CA file is relative to the home directory. With TLS and no CA file,
+// the rpc.cert file in the home directory is used if present, the system roots otherwise.
+func (a *State) grpcCredentials(node GRPCNode) (credentials.TransportCredentials, error) {
+	if !node.TLS {
+		return insecure.NewCredentials(), nil
+	}
+
+	caFile := node.CAFile
+	if caFile == "" {
+		if _, err := os.Stat(a.homeFile(CA_FILE)); err == nil {
+			caFile = CA_FILE
+		}
+	}
+	if caFile == "" {
+		return credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12}), nil
+	}
+	return credentials.NewClientTLSFromFile(a.homeFile(caFile), "")
+}
+
+// Get the configured Side gRPC endpoints, the grpc endpoint first
+func (a *State) grpcNodes() []GRPCNode {
+	primary := GRPCNode{
+		GRPC:   a.Config.Side.GRPC,
+		TLS:    a.Config.Side.GRPCTLS,
+		CAFile: a.Config.Side.GRPCCAFile,
+	}
+	return append([]GRPCNode{primary}, a.Config.Side.GRPCNodes...)
+}
+
+func (a *State) dialTimeout() time.Duration {
+	if a.Config.Side.DialTimeout <= 0 {
+		return DefaultDialTimeout * time.Second
+	}
+	return time.Duration(a.Config.Side.DialTimeout) * time.Second
+}
+
+// Connect to the Side gRPC endpoints
+// Starts with the first endpoint that is ready within the dial timeout,
+// and fails if none of them is reachable.
+func (a *State) dialSide() (*grpcPool, error) {
+	pool := &grpcPool{log: a.Log}
+	for _, node := range a.grpcNodes() {
+		creds, err := a.grpcCredentials(node)
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("failed to load the credentials of %s: %w", node.GRPC, err)
+		}
+		conn, err := grpc.Dial(node.GRPC, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		pool.endpoints = append(pool.endpoints, grpcEndpoint{address: node.GRPC, conn: conn})
+	}
+
+	for i, e := range pool.endpoints {
+		if waitForReady(e.conn, a.dialTimeout()) {
+			pool.current = i
+			return pool, nil
+		}
+		a.Log.Warn("Side gRPC endpoint is not reachable", zap.String("endpoint", e.address))
+	}
+
+	pool.Close()
+	return nil, errors.New("none of the Side gRPC endpoints is reachable")
+}
+
+// Wait until the connection is ready, or the timeout is reached
+func waitForReady(conn *grpc.ClientConn, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		if state == connectivity.Ready {
+			return true
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return false
+		}
+	}
+}
+
+// Get the current endpoint
+func (p *grpcPool) endpoint() grpcEndpoint {
+	p.Lock()
+	defer p.Unlock()
+	return p.endpoints[p.current]
+}
+
+// Rotate to the next endpoint, unless another call has already rotated away from the failed one
+func (p *grpcPool) rotate(failed grpcEndpoint, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.endpoints[p.current].address != failed.address {
+		return
+	}
+	p.current = (p.current + 1) % len(p.endpoints)
+	p.log.Warn("Side gRPC endpoint failed, rotating",
+		zap.String("from", failed.address),
+		zap.String("to", p.endpoints[p.current].address),
+		zap.Error(err),
+	)
+}
+
+// Invoke the call on the current endpoint
+// Calls that did not reach the endpoint are retried on the next ones,
+// other endpoint failures rotate the endpoint for the subsequent calls.
+func (p *grpcPool) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	var err error
+	for i := 0; i < len(p.endpoints); i++ {
+		e := p.endpoint()
+		err = e.conn.Invoke(ctx, method, args, reply, opts...)
+		if err == nil || !isEndpointError(err) {
+			return err
+		}
+
+		p.rotate(e, err)
+		if !isRetryableEndpointError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// Open the stream on the current endpoint
+func (p *grpcPool) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	e := p.endpoint()
+	stream, err := e.conn.NewStream(ctx, desc, method, opts...)
+	if err != nil && isEndpointError(err) {
+		p.rotate(e, err)
+	}
+	return stream, err
+}
+
+// Close all the connections
+func (p *grpcPool) Close() error {
+	var err error
+	for _, e := range p.endpoints {
+		if cerr := e.conn.Close(); cerr != nil {
+			err = cerr
+		}
+	}
+	return err
+}
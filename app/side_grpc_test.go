@@ -0,0 +1,40 @@
+package app
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_DialSideUnreachable(t *testing.T) {
+
+	a := &State{Config: defaultConfig("mainnet"), Log: zap.NewNop(), HomePath: t.TempDir()}
+	a.Config.Side.GRPC = "127.0.0.1:1"
+	a.Config.Side.GRPCNodes = []GRPCNode{{GRPC: "127.0.0.1:2"}}
+	a.Config.Side.DialTimeout = 1
+
+	// fails instead of hanging when no endpoint is reachable
+	if _, err := a.dialSide(); err == nil {
+		t.Errorf("Expected an error")
+	}
+
+	// the CA file must exist with TLS enabled
+	if _, err := a.grpcCredentials(GRPCNode{GRPC: "side:9090", TLS: true, CAFile: "missing.pem"}); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func Test_IsEndpointError(t *testing.T) {
+
+	if !isRetryableEndpointError(status.Error(codes.Unavailable, "connection refused")) {
+		t.Errorf("Expected unavailable to be retryable")
+	}
+	if !isEndpointError(status.Error(codes.DeadlineExceeded, "timeout")) || isRetryableEndpointError(status.Error(codes.DeadlineExceeded, "timeout")) {
+		t.Errorf("Expected deadline exceeded to rotate without retrying")
+	}
+	if isEndpointError(status.Error(codes.NotFound, "not found")) {
+		t.Errorf("Expected not found to be returned as is")
+	}
+}
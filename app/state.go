@@ -3,12 +3,12 @@ package app
 import (
 	"context"
 	"fmt"
-	"log"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg"
-	"github.com/btcsuite/btcd/rpcclient"
 	"github.com/cosmos/cosmos-sdk/client/tx"
 	"github.com/cosmos/cosmos-sdk/crypto/keyring"
 
@@ -19,18 +19,33 @@ import (
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	txtypes "github.com/cosmos/cosmos-sdk/types/tx"
 	auth "github.com/cosmos/cosmos-sdk/x/auth/types"
+	"github.com/sideprotocol/shuttler/app/store"
 	btclightclient "github.com/sideprotocol/side/x/btcbridge/types"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
 	DefaultTimeout = 15 * time.Second
 )
 
+// Sync status of the header relay
+// It's guarded by a lock, as it's read by the HTTP handlers.
+type relayStatus struct {
+	sync.RWMutex
+	// Side chain synced to the bitcoin network
+	synced bool
+	// Set when header relay can not continue, e.g. a reorg deeper than the max depth
+	haltErr error
+	// Paused by the admin API
+	relayPaused   bool
+	signingPaused bool
+}
+
 // AppState is the modifiable state of the application.
 // App connects both the bitcoin and cosmos network
 // Connect to the bitcoin network via RPC; txindex, zmq must be enabled
+// or via an Esplora/Electrs REST API
 // Connect to the cosmos network via gRPC
 type State struct {
 	// General application state
@@ -48,16 +63,22 @@ type State struct {
 	// Bitcoin Variables
 	// Last Bitcoin Block
 	lastBitcoinBlock *btcjson.GetBlockHeaderVerboseResult
-	// Side chain synced to the bitcoin network
-	synced bool
-	rpc    *rpcclient.Client
+	// Sync status, also read by the health endpoints
+	status relayStatus
+	rpc    BitcoinBackend
+
+	// Persistent checkpoints of the relayer
+	store *store.Store
+	// Operations requested by the admin API, run by the main loop
+	adminRequests chan func()
 
 	// Cosmos Variables
 	account *auth.BaseAccount
+	seq     sequenceManager
 	params  *btclightclient.Params
 	// TrustHeader     wire.BlockHeader
 	txFactory       tx.Factory
-	gRPC            *grpc.ClientConn
+	gRPC            *grpcPool
 	grpcQueryClient btclightclient.QueryClient
 	txServiceClient txtypes.ServiceClient
 }
@@ -69,9 +90,9 @@ func NewAppState(home string) *State {
 		h = DefaultHome
 	}
 	return &State{
-		Viper:    viper.New(),
-		HomePath: h,
-		synced:   false,
+		Viper:         viper.New(),
+		HomePath:      h,
+		adminRequests: make(chan func()),
 	}
 }
 
@@ -85,8 +106,14 @@ func (a *State) Init() error {
 		return err
 	}
 
-	// Set up a connection to the server.
-	conn, err := grpc.Dial(a.Config.Side.GRPC, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if a.Log == nil {
+		if err := a.InitLogger(); err != nil {
+			return err
+		}
+	}
+
+	// Set up a connection to the Side gRPC endpoints.
+	conn, err := a.dialSide()
 	if err != nil {
 		return err
 	}
@@ -96,10 +123,6 @@ func (a *State) Init() error {
 	a.txServiceClient = txtypes.NewServiceClient(conn)
 	a.grpcQueryClient = btclightclient.NewQueryClient(conn)
 
-	if a.Log == nil {
-		a.InitLogger(a.Config.Global.LogLevel)
-	}
-
 	a.initTxFactory()
 
 	return nil
@@ -135,6 +158,45 @@ func (a *State) QueryChainTip() (*btclightclient.QueryChainTipResponse, error) {
 	return res, nil
 }
 
+// Query Light Client Block Header by Height
+func (a *State) QueryBlockHeaderByHeight(height uint64) (*btclightclient.BlockHeader, error) {
+	// Timeout context for our queries
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	res, err := a.grpcQueryClient.QueryBlockHeaderByHeight(ctx, &btclightclient.QueryBlockHeaderByHeightRequest{Height: height})
+	if err != nil {
+		return nil, err
+	}
+	return res.BlockHeader, nil
+}
+
+// Query Light Client Block Header by Hash
+func (a *State) QueryBlockHeaderByHash(hash string) (*btclightclient.BlockHeader, error) {
+	// Timeout context for our queries
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	res, err := a.grpcQueryClient.QueryBlockHeaderByHash(ctx, &btclightclient.QueryBlockHeaderByHashRequest{Hash: hash})
+	if err != nil {
+		return nil, err
+	}
+	return res.BlockHeader, nil
+}
+
+// Query Parameters of Light Client without checking the permission
+func (a *State) QueryParams() (*btclightclient.Params, error) {
+	// Timeout context for our queries
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	res, err := a.grpcQueryClient.QueryParams(ctx, &btclightclient.QueryParamsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return &res.Params, nil
+}
+
 // Query Parameters of Light Client
 func (a *State) QueryAndCheckLightClientPermission() (*btclightclient.QueryParamsResponse, error) {
 	// Timeout context for our queries
@@ -157,26 +219,22 @@ func (a *State) QueryAndCheckLightClientPermission() (*btclightclient.QueryParam
 }
 
 // Query Sequence of Side Account
+// Returns the next sequence to be used, including the pending transactions
 func (a *State) QuerySequence() (uint64, error) {
-	// Query account info
-	account, err := a.queryAccountInfo()
-	if err != nil {
-		return 0, err
+	a.seq.Lock()
+	defer a.seq.Unlock()
+
+	if !a.seq.loaded {
+		if err := a.refreshAccount(); err != nil {
+			return 0, err
+		}
 	}
-	return account.Sequence, nil
+	return a.seq.next, nil
 }
 
 // Query Cosmos Account Auth Info
-// Sequence number is incremented for each transaction
 func (a *State) queryAccountInfo() (*auth.BaseAccount, error) {
 
-	// Return the account if it's already loaded
-	// Increment the sequence number for each transaction
-	if a.account != nil {
-		a.account.Sequence++
-		return a.account, nil
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
 	defer cancel()
 
@@ -192,81 +250,182 @@ func (a *State) queryAccountInfo() (*auth.BaseAccount, error) {
 }
 
 // SendTx sends a transaction to the sidechain
+// Failures are returned as *TxError, so that the caller can decide whether to retry, skip or halt
 func (a *State) SendSideTx(msg sdk.Msg) error {
-	// Encode the message
-	// create a new encoding config
-	encodingConfig := MakeEncodingConfig()
-	txBuilder := encodingConfig.TxConfig.NewTxBuilder()
-	txBuilder.SetGasLimit(a.Config.Side.Gas)
-	txBuilder.SetFeeAmount(sdk.Coins{sdk.NewInt64Coin("uside", int64(2000))})
-	txBuilder.SetMsgs(msg)
-
-	// Estimate the gas
-	// txBytes, err := encodingConfig.TxConfig.TxEncoder()(txBuilder.GetTx())
-	// if err != nil {
-	// 	log.Fatalf("failed to encode tx: %v", err)
-	// 	return err
-	// }
-	// estRes, err := a.txServiceClient.Simulate(context.Background(), &txtypes.SimulateRequest{
-	// 	TxBytes: txBytes,
-	// })
-	// if err != nil {
-	// 	log.Fatalf("failed to estimate gas: %v", err)
-	// 	return err
-	// }
-	// log.Printf("Estimated gas: %d/%d\n", estRes.GasInfo.GasUsed, estRes.GasInfo.GasWanted)
-	// txBuilder.SetGasLimit(uint64(float32(estRes.GasInfo.GasUsed) * 2))
+	_, err := a.BroadcastSideTx(msg)
+	return err
+}
 
-	// Sign the transaction
-	// Query Account info
-	account, err := a.queryAccountInfo()
+// BroadcastSideTx sends a transaction to the sidechain and returns the response
+// The transaction is retried with the corrected sequence if the account sequence mismatches
+// If enabled, it waits until the transaction is included in a block,
+// and returns the final response with the height and the gas used.
+func (a *State) BroadcastSideTx(msg sdk.Msg) (*sdk.TxResponse, error) {
+	res, err := a.broadcastSideTx(msg)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if !a.Config.Side.WaitForInclusion {
+		return res, nil
+	}
+	return a.waitForInclusion(res.TxHash)
+}
+
+// Broadcast the transaction, retrying on sequence mismatches
+func (a *State) broadcastSideTx(msg sdk.Msg) (*sdk.TxResponse, error) {
+	return a.broadcastWithSequence(func(sequence uint64) (*sdk.TxResponse, error) {
+		return a.sendSideTx(msg, sequence)
+	})
+}
+
+// Send the transaction with the next sequence, correcting the sequence on mismatches
+func (a *State) broadcastWithSequence(send func(sequence uint64) (*sdk.TxResponse, error)) (*sdk.TxResponse, error) {
+	a.seq.Lock()
+	defer a.seq.Unlock()
+
+	var err error
+	for i := 0; i < maxSequenceRetries; i++ {
+		// Query Account info
+		if !a.seq.loaded {
+			if err = a.refreshAccount(); err != nil {
+				return nil, newGRPCError(err)
+			}
+		}
+
+		var res *sdk.TxResponse
+		res, err = send(a.seq.next)
+		if !IsTxErrorKind(err, ErrKindSequenceMismatch) {
+			return res, err
+		}
+
+		a.Log.Warn("Account sequence mismatch, retrying", zap.Uint64("sequence", a.seq.next), zap.Error(err))
+		a.recoverSequence(err)
+	}
+	return nil, err
+}
+
+// Poll the transaction until it's included in a block or the inclusion timeout is reached
+// Returns an error if the transaction failed in DeliverTx
+// The caller is blocked meanwhile, so the timeout bounds the delay of the main loop
+func (a *State) waitForInclusion(txhash string) (*sdk.TxResponse, error) {
+	interval := time.Duration(a.Config.Side.InclusionPollInterval) * time.Second
+	if interval <= 0 {
+		interval = DefaultInclusionPollInterval * time.Second
+	}
+	timeout := time.Duration(a.Config.Side.InclusionTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultInclusionTimeout * time.Second
 	}
 
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		res, err := a.txServiceClient.GetTx(ctx, &txtypes.GetTxRequest{Hash: txhash})
+		cancel()
+		if err != nil {
+			// Not included yet
+			if status.Code(err) != codes.NotFound {
+				a.Log.Debug("Failed to query transaction", zap.String("hash", txhash), zap.Error(err))
+			}
+			continue
+		}
+
+		a.removePending(txhash)
+
+		if res.TxResponse.Code != 0 {
+			txErr := newTxResponseError(res.TxResponse.Codespace, res.TxResponse.Code, res.TxResponse.RawLog)
+			a.Log.Error("Transaction failed", zap.String("hash", txhash), zap.Int64("height", res.TxResponse.Height), zap.String("kind", txErr.Kind.String()), zap.String("error", res.TxResponse.RawLog))
+			return res.TxResponse, txErr
+		}
+
+		a.Log.Info("Transaction included",
+			zap.String("hash", txhash),
+			zap.Int64("height", res.TxResponse.Height),
+			zap.Int64("gas_used", res.TxResponse.GasUsed),
+			zap.Int64("gas_wanted", res.TxResponse.GasWanted),
+		)
+		return res.TxResponse, nil
+	}
+
+	return nil, &TxError{Kind: ErrKindTransient, Err: fmt.Errorf("transaction %s not included within %s", txhash, timeout)}
+}
+
+// Sign and broadcast the transaction with the given sequence
+func (a *State) sendSideTx(msg sdk.Msg, sequence uint64) (*sdk.TxResponse, error) {
+	// Encode the message
+	// create a new encoding config
+	encodingConfig := MakeEncodingConfig()
+
 	// Create Signing Factory
 	txf := a.txFactory
-	txf = txf.WithGasPrices("0.00001uside")
-	txf = txf.WithFees("2000uside")
-	txf = txf.WithFeePayer(account.GetAddress())
+	txf = txf.WithFeePayer(a.account.GetAddress())
 	txf = txf.WithTxConfig(encodingConfig.TxConfig)
-	txf = txf.WithAccountNumber(account.AccountNumber)
-	txf = txf.WithSequence(account.Sequence)
+	txf = txf.WithAccountNumber(a.account.AccountNumber)
+	txf = txf.WithSequence(sequence)
 	txf = txf.WithChainID(a.Config.Side.ChainID)
 
+	// Estimate the gas and the fee
+	gas, err := a.estimateGas(txf, msg)
+	if err != nil {
+		return nil, err
+	}
+	fee, err := a.calculateFee(gas)
+	if err != nil {
+		return nil, &TxError{Kind: ErrKindPermanent, Err: err}
+	}
+
+	txBuilder := encodingConfig.TxConfig.NewTxBuilder()
+	txBuilder.SetGasLimit(gas)
+	txBuilder.SetFeeAmount(sdk.NewCoins(fee))
+	txBuilder.SetMsgs(msg)
+
+	// Sign the transaction
 	err = tx.Sign(txf, InternalKeyringName, txBuilder, true)
 	if err != nil {
-		log.Fatalf("failed to sign tx: %v", err)
-		return err
+		return nil, &TxError{Kind: ErrKindPermanent, Err: fmt.Errorf("failed to sign tx: %w", err)}
 	}
 
 	txBytes, err := encodingConfig.TxConfig.TxEncoder()(txBuilder.GetTx())
 	if err != nil {
-		log.Fatalf("failed to encode tx: %v", err)
-		return err
+		return nil, &TxError{Kind: ErrKindPermanent, Err: fmt.Errorf("failed to encode tx: %w", err)}
 	}
 
 	// Broadcast the transaction
-	res, err := a.txServiceClient.BroadcastTx(context.Background(), &txtypes.BroadcastTxRequest{
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	res, err := a.txServiceClient.BroadcastTx(ctx, &txtypes.BroadcastTxRequest{
 		TxBytes: txBytes,
 		Mode:    txtypes.BroadcastMode_BROADCAST_MODE_SYNC, // Change as needed
 	})
 	if err != nil {
-		log.Fatalf("failed to broadcast tx: %v", err)
-		return err
+		return nil, newGRPCError(fmt.Errorf("failed to broadcast tx: %w", err))
 	}
 
+	// The transaction is rejected by CheckTx, the sequence is not consumed
 	if res.TxResponse.Code != 0 {
-		a.Log.Fatal("message failed", zap.String("error", res.TxResponse.RawLog))
-		return fmt.Errorf("message failed: %s", res.TxResponse.RawLog)
+		txErr := newTxResponseError(res.TxResponse.Codespace, res.TxResponse.Code, res.TxResponse.RawLog)
+		a.Log.Error("message failed", zap.String("kind", txErr.Kind.String()), zap.String("error", res.TxResponse.RawLog))
+		return nil, txErr
 	}
 
-	fmt.Printf("Transaction broadcasted with TX hash: %s\n", res.TxResponse.TxHash)
-	return nil
+	a.commitSequence(sequence, res.TxResponse.TxHash)
+	addSideTxFee(fee)
+
+	a.Log.Info("Transaction broadcasted", zap.String("txhash", res.TxResponse.TxHash), zap.Uint64("sequence", sequence))
+	return res.TxResponse, nil
 }
 
-func (a *State) InitLogger(configLogLevel string) error {
-	a.Log = zap.Must(zap.NewDevelopment())
+// Initialize the logger from the flags and the [global] config
+func (a *State) InitLogger() error {
+	log, err := NewLogger(a.logOptions())
+	if err != nil {
+		return err
+	}
+	a.Log = log
 	return nil
 }
 
@@ -279,11 +438,14 @@ func (a *State) initTxFactory() {
 		panic(err)
 	}
 
+	if a.Config.Side.GasAdjustment <= 0 {
+		a.Config.Side.GasAdjustment = DefaultGasAdjustment
+	}
+
 	f := tx.Factory{}
 	f = f.WithChainID(a.Config.Side.ChainID)
 	f = f.WithFromName(InternalKeyringName)
-	f = f.WithGas(a.Config.Side.Gas)
-	f = f.WithGasAdjustment(1.5)
+	f = f.WithGasAdjustment(a.Config.Side.GasAdjustment)
 	f = f.WithKeybase(kb).WithSignMode(signing.SignMode_SIGN_MODE_DIRECT)
 	a.txFactory = f
 }
@@ -294,6 +456,9 @@ func (a *State) loadConfigFile(_ context.Context) error {
 	cb := NewConfigBuilder(a.HomePath)
 	// unmarshall them into the wrapper struct
 	cfg := cb.LoadConfigFile()
+	if err := validateDepositSender(cfg.Bitcoin.DepositSender); err != nil {
+		return err
+	}
 	a.Config = cfg
 
 	return nil
@@ -303,13 +468,16 @@ func (a *State) InitRPC() error {
 
 	a.QueryAndCheckLightClientPermission()
 
-	client, err := rpcclient.New(&rpcclient.ConnConfig{
-		Host:         a.Config.Bitcoin.RPC,
-		User:         a.Config.Bitcoin.RPCUser,
-		Pass:         a.Config.Bitcoin.RPCPassword,
-		HTTPPostMode: true,
-		DisableTLS:   true,
-	}, nil)
+	return a.InitBitcoinBackend()
+}
+
+// Initialize the bitcoin backend only, for the commands that don't relay
+func (a *State) InitBitcoinBackend() error {
+	if a.rpc != nil {
+		return nil
+	}
+
+	client, err := a.newBitcoinBackend()
 	if err != nil {
 		return err
 	}
@@ -318,7 +486,47 @@ func (a *State) InitRPC() error {
 	return nil
 }
 
+// Open the checkpoint store under the home directory
+// The store is locked by the process, so only the daemon should open it.
+func (a *State) InitStore() error {
+	s, err := store.Open(filepath.Join(a.HomePath, "data", store.DefaultDBName))
+	if err != nil {
+		return err
+	}
+	a.store = s
+	return nil
+}
+
 // Close the application state
 func (a *State) Close() {
 	a.gRPC.Close()
+	if a.store != nil {
+		a.store.Close()
+	}
+}
+
+func (a *State) isSynced() bool {
+	a.status.RLock()
+	defer a.status.RUnlock()
+	return a.status.synced
+}
+
+func (a *State) setSynced(synced bool) {
+	a.status.Lock()
+	defer a.status.Unlock()
+	a.status.synced = synced
+}
+
+// Get the error that halted the header relay, nil if not halted
+func (a *State) halted() error {
+	a.status.RLock()
+	defer a.status.RUnlock()
+	return a.status.haltErr
+}
+
+// Halt the header relay, it requires an operator to resolve
+func (a *State) halt(err error) {
+	a.status.Lock()
+	defer a.status.Unlock()
+	a.status.haltErr = err
 }
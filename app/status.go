@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	bank "github.com/cosmos/cosmos-sdk/x/bank/types"
+	btcbridge "github.com/sideprotocol/side/x/btcbridge/types"
+)
+
+// RelayerStatus is the status of the relayer on bitcoin and the sidechain
+type RelayerStatus struct {
+	BitcoinHeight     int32          `json:"bitcoin_height"`
+	BitcoinHash       string         `json:"bitcoin_hash"`
+	LightClientHeight uint64         `json:"light_client_height"`
+	LightClientHash   string         `json:"light_client_hash"`
+	Lag               int64          `json:"lag"`
+	Sender            string         `json:"sender"`
+	Authorized        bool           `json:"authorized"`
+	Balance           string         `json:"balance"`
+	Sequence          uint64         `json:"sequence"`
+	Vaults            []*VaultStatus `json:"vaults"`
+	SigningRequests   map[string]int `json:"signing_requests"`
+	Errors            []string       `json:"errors,omitempty"`
+}
+
+// VaultStatus is a vault configured in the btcbridge params
+type VaultStatus struct {
+	Address   string `json:"address"`
+	PubKey    string `json:"pub_key"`
+	AssetType string `json:"asset_type"`
+	Version   uint64 `json:"version"`
+}
+
+// Query the balance of the relayer account in the gas price denom
+func (a *State) queryBalance() (*sdk.Coin, error) {
+	price, err := a.gasPrice()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	res, err := bank.NewQueryClient(a.gRPC).Balance(ctx, &bank.QueryBalanceRequest{Address: a.Config.Side.Sender, Denom: price.Denom})
+	if err != nil {
+		return nil, err
+	}
+	return res.Balance, nil
+}
+
+// Count the signing requests of each status
+func (a *State) countSigningRequests() (map[string]int, error) {
+	statuses := []int32{}
+	for s := range btcbridge.SigningStatus_name {
+		if s != int32(btcbridge.SigningStatus_SIGNING_STATUS_UNSPECIFIED) {
+			statuses = append(statuses, s)
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i] < statuses[j] })
+
+	counts := map[string]int{}
+	for _, s := range statuses {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		res, err := a.grpcQueryClient.QuerySigningRequest(ctx, &btcbridge.QuerySigningRequestRequest{Status: btcbridge.SigningStatus(s)})
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		counts[btcbridge.SigningStatus(s).String()] = len(res.Requests)
+	}
+	return counts, nil
+}
+
+// QueryRelayerStatus queries the status of the relayer without starting it
+// The bitcoin backend is created if not initialized yet. Failed queries are reported
+// in the errors, so that the status is shown as far as possible.
+func (a *State) QueryRelayerStatus() *RelayerStatus {
+	status := &RelayerStatus{Sender: a.Config.Side.Sender, Vaults: []*VaultStatus{}}
+	fail := func(what string, err error) {
+		status.Errors = append(status.Errors, fmt.Sprintf("%s: %v", what, err))
+	}
+
+	if err := a.InitBitcoinBackend(); err != nil {
+		fail("bitcoin backend", err)
+	} else {
+		if tip, err := a.queryBitcoinTip(); err != nil {
+			fail("bitcoin tip", err)
+		} else {
+			status.BitcoinHeight = tip.Height
+			status.BitcoinHash = tip.Hash
+		}
+	}
+
+	if tip, err := a.QueryChainTip(); err != nil {
+		fail("light client tip", err)
+	} else {
+		status.LightClientHeight = tip.Height
+		status.LightClientHash = tip.Hash
+		if status.BitcoinHeight > 0 {
+			status.Lag = int64(status.BitcoinHeight) - int64(tip.Height)
+		}
+	}
+
+	if params, err := a.QueryParams(); err != nil {
+		fail("params", err)
+	} else {
+		status.Authorized = params.IsAuthorizedSender(a.Config.Side.Sender)
+		for _, v := range params.Vaults {
+			status.Vaults = append(status.Vaults, &VaultStatus{
+				Address:   v.Address,
+				PubKey:    v.PubKey,
+				AssetType: v.AssetType.String(),
+				Version:   v.Version,
+			})
+		}
+	}
+
+	if balance, err := a.queryBalance(); err != nil {
+		fail("balance", err)
+	} else if balance != nil {
+		status.Balance = balance.String()
+	}
+
+	if account, err := a.queryAccountInfo(); err != nil {
+		fail("account", err)
+	} else {
+		status.Sequence = account.Sequence
+	}
+
+	if counts, err := a.countSigningRequests(); err != nil {
+		fail("signing requests", err)
+	} else {
+		status.SigningRequests = counts
+	}
+
+	return status
+}
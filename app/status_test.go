@@ -0,0 +1,33 @@
+package app
+
+import (
+	"testing"
+
+	btcbridge "github.com/sideprotocol/side/x/btcbridge/types"
+	"go.uber.org/zap"
+)
+
+func Test_CountSigningRequests(t *testing.T) {
+
+	a := &State{Config: defaultConfig("regtest"), Log: zap.NewNop()}
+	a.grpcQueryClient = &mockQueryClient{requests: []*btcbridge.BitcoinSigningRequest{
+		{Txid: "a", Status: btcbridge.SigningStatus_SIGNING_STATUS_CREATED},
+		{Txid: "b", Status: btcbridge.SigningStatus_SIGNING_STATUS_CREATED},
+		{Txid: "c", Status: btcbridge.SigningStatus_SIGNING_STATUS_BROADCASTED},
+	}}
+
+	counts, err := a.countSigningRequests()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if counts[btcbridge.SigningStatus_SIGNING_STATUS_CREATED.String()] != 2 || counts[btcbridge.SigningStatus_SIGNING_STATUS_BROADCASTED.String()] != 1 {
+		t.Errorf("Unexpected counts %v", counts)
+	}
+	// statuses without requests are reported as well
+	if _, ok := counts[btcbridge.SigningStatus_SIGNING_STATUS_CONFIRMED.String()]; !ok {
+		t.Errorf("Expected the confirmed count, got %v", counts)
+	}
+	if _, ok := counts[btcbridge.SigningStatus_SIGNING_STATUS_UNSPECIFIED.String()]; ok {
+		t.Errorf("Unexpected unspecified count")
+	}
+}
@@ -0,0 +1,242 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// DefaultDBName is the name of the database file under the home directory
+	DefaultDBName = "shuttler.db"
+
+	// openTimeout is the time to wait for the file lock held by another process
+	openTimeout = time.Second
+)
+
+// TxKind is the kind of a bitcoin transaction submitted to the sidechain
+type TxKind string
+
+const (
+	Deposit    TxKind = "deposits"
+	Withdrawal TxKind = "withdrawals"
+)
+
+var (
+	metaBucket           = []byte("meta")
+	skippedDepositBucket = []byte("skipped-deposits")
+
+	keyLastHeader    = []byte("last-header")
+	keyScannedHeight = []byte("scanned-height")
+	keyRescanHeight  = []byte("rescan-height")
+)
+
+// Header is the last bitcoin block header relayed to the sidechain
+type Header struct {
+	Hash   string `json:"hash"`
+	Height int32  `json:"height"`
+}
+
+// SkippedDeposit is a deposit transaction skipped by the relayer, kept for a manual review
+type SkippedDeposit struct {
+	Txid   string    `json:"txid"`
+	Height int32     `json:"height"`
+	Amount int64     `json:"amount"`
+	Reason string    `json:"reason"`
+	Detail string    `json:"detail"`
+	Time   time.Time `json:"time"`
+}
+
+// Store persists the relayer checkpoints, so that the daemon
+// can resume where it left off after a restart.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (or creates) the database at the given path
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	// Create all the buckets up front
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{metaBucket, skippedDepositBucket, []byte(Deposit), []byte(Withdrawal)} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// LastHeader returns the last relayed header, nil if nothing has been relayed yet
+func (s *Store) LastHeader() (*Header, error) {
+	var header *Header
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bz := tx.Bucket(metaBucket).Get(keyLastHeader)
+		if bz == nil {
+			return nil
+		}
+		header = &Header{}
+		return json.Unmarshal(bz, header)
+	})
+	return header, err
+}
+
+// SetLastHeader records the last relayed header
+func (s *Store) SetLastHeader(header *Header) error {
+	bz, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(keyLastHeader, bz)
+	})
+}
+
+// Get the height of the key, and whether it's set
+func (s *Store) getHeight(key []byte) (int32, bool, error) {
+	var height int32
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bz := tx.Bucket(metaBucket).Get(key)
+		if len(bz) == 4 {
+			height = int32(binary.BigEndian.Uint32(bz))
+			found = true
+		}
+		return nil
+	})
+	return height, found, err
+}
+
+func (s *Store) setHeight(key []byte, height int32) error {
+	bz := make([]byte, 4)
+	binary.BigEndian.PutUint32(bz, uint32(height))
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(key, bz)
+	})
+}
+
+// ScannedHeight returns the last confirmed height scanned for vault transactions,
+// and false if nothing has been scanned yet
+func (s *Store) ScannedHeight() (int32, bool, error) {
+	return s.getHeight(keyScannedHeight)
+}
+
+// SetScannedHeight records the last confirmed height scanned for vault transactions
+func (s *Store) SetScannedHeight(height int32) error {
+	return s.setHeight(keyScannedHeight, height)
+}
+
+// RescanHeight returns the last height of the historical rescan, 0 if none
+func (s *Store) RescanHeight() (int32, error) {
+	height, _, err := s.getHeight(keyRescanHeight)
+	return height, err
+}
+
+// SetRescanHeight records the last height of the historical rescan, so that it can be resumed
+func (s *Store) SetRescanHeight(height int32) error {
+	return s.setHeight(keyRescanHeight, height)
+}
+
+// IsSubmitted checks if the transaction has already been submitted to the sidechain
+func (s *Store) IsSubmitted(kind TxKind, txid string) (bool, error) {
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket([]byte(kind)).Get([]byte(txid)) != nil
+		return nil
+	})
+	return found, err
+}
+
+// MarkSubmitted records the transaction as submitted, together with the block height it was found in
+func (s *Store) MarkSubmitted(kind TxKind, txid string, height int32) error {
+	bz := make([]byte, 4)
+	binary.BigEndian.PutUint32(bz, uint32(height))
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(kind)).Put([]byte(txid), bz)
+	})
+}
+
+// UnmarkSubmittedFrom removes the transactions found at or above the height, e.g. in the blocks orphaned by a reorg
+// Returns the number of transactions removed.
+func (s *Store) UnmarkSubmittedFrom(height int32) (int, error) {
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, kind := range []TxKind{Deposit, Withdrawal} {
+			b := tx.Bucket([]byte(kind))
+			txids := [][]byte{}
+			if err := b.ForEach(func(txid, bz []byte) error {
+				if len(bz) == 4 && int32(binary.BigEndian.Uint32(bz)) >= height {
+					txids = append(txids, txid)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			for _, txid := range txids {
+				if err := b.Delete(txid); err != nil {
+					return err
+				}
+			}
+			removed += len(txids)
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// AddSkippedDeposit records the skipped deposit, replacing a previous record of the transaction
+func (s *Store) AddSkippedDeposit(deposit *SkippedDeposit) error {
+	bz, err := json.Marshal(deposit)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(skippedDepositBucket).Put([]byte(deposit.Txid), bz)
+	})
+}
+
+// SkippedDeposits returns the skipped deposits, ordered by txid
+func (s *Store) SkippedDeposits() ([]*SkippedDeposit, error) {
+	deposits := []*SkippedDeposit{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(skippedDepositBucket).ForEach(func(_, bz []byte) error {
+			deposit := &SkippedDeposit{}
+			if err := json.Unmarshal(bz, deposit); err != nil {
+				return err
+			}
+			deposits = append(deposits, deposit)
+			return nil
+		})
+	})
+	return deposits, err
+}
+
+// RemoveSkippedDeposit removes the skipped deposit once it's been reviewed
+func (s *Store) RemoveSkippedDeposit(txid string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(skippedDepositBucket).Delete([]byte(txid))
+	})
+}
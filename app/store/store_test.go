@@ -0,0 +1,131 @@
+package store_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sideprotocol/shuttler/app/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "data", store.DefaultDBName)
+	s, err := store.Open(path)
+	require.NoError(t, err)
+
+	// empty store
+	header, err := s.LastHeader()
+	require.NoError(t, err)
+	require.Nil(t, header)
+
+	height, scanned, err := s.ScannedHeight()
+	require.NoError(t, err)
+	require.False(t, scanned)
+	require.Equal(t, int32(0), height)
+
+	// write checkpoints
+	require.NoError(t, s.SetLastHeader(&store.Header{Hash: "000001d36a0074bd4ec73f19dadc6a2df1c7b049daff568e0346c06ea1297e8e", Height: 200}))
+	require.NoError(t, s.SetScannedHeight(194))
+	require.NoError(t, s.MarkSubmitted(store.Deposit, "txid1", 194))
+
+	// checkpoints survive a restart
+	require.NoError(t, s.Close())
+	s, err = store.Open(path)
+	require.NoError(t, err)
+	defer s.Close()
+
+	header, err = s.LastHeader()
+	require.NoError(t, err)
+	require.Equal(t, int32(200), header.Height)
+	require.Equal(t, "000001d36a0074bd4ec73f19dadc6a2df1c7b049daff568e0346c06ea1297e8e", header.Hash)
+
+	height, scanned, err = s.ScannedHeight()
+	require.NoError(t, err)
+	require.True(t, scanned)
+	require.Equal(t, int32(194), height)
+
+	// a height of 0 is set, not the same as nothing scanned
+	require.NoError(t, s.SetScannedHeight(0))
+	height, scanned, err = s.ScannedHeight()
+	require.NoError(t, err)
+	require.True(t, scanned)
+	require.Equal(t, int32(0), height)
+
+	submitted, err := s.IsSubmitted(store.Deposit, "txid1")
+	require.NoError(t, err)
+	require.True(t, submitted)
+
+	// deposits and withdrawals are tracked separately
+	submitted, err = s.IsSubmitted(store.Withdrawal, "txid1")
+	require.NoError(t, err)
+	require.False(t, submitted)
+}
+
+func TestRescanHeight(t *testing.T) {
+
+	s, err := store.Open(filepath.Join(t.TempDir(), store.DefaultDBName))
+	require.NoError(t, err)
+	defer s.Close()
+
+	// the rescan is tracked separately from the scanner
+	require.NoError(t, s.SetScannedHeight(300))
+	require.NoError(t, s.SetRescanHeight(120))
+
+	height, err := s.RescanHeight()
+	require.NoError(t, err)
+	require.Equal(t, int32(120), height)
+
+	height, _, err = s.ScannedHeight()
+	require.NoError(t, err)
+	require.Equal(t, int32(300), height)
+}
+
+func TestUnmarkSubmittedFrom(t *testing.T) {
+
+	s, err := store.Open(filepath.Join(t.TempDir(), store.DefaultDBName))
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.MarkSubmitted(store.Deposit, "txid1", 99))
+	require.NoError(t, s.MarkSubmitted(store.Deposit, "txid2", 100))
+	require.NoError(t, s.MarkSubmitted(store.Withdrawal, "txid3", 101))
+
+	// the transactions of the orphaned blocks are forgotten
+	removed, err := s.UnmarkSubmittedFrom(100)
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+
+	for txid, expected := range map[string]bool{"txid1": true, "txid2": false} {
+		submitted, err := s.IsSubmitted(store.Deposit, txid)
+		require.NoError(t, err)
+		require.Equal(t, expected, submitted)
+	}
+	submitted, err := s.IsSubmitted(store.Withdrawal, "txid3")
+	require.NoError(t, err)
+	require.False(t, submitted)
+}
+
+func TestSkippedDeposits(t *testing.T) {
+
+	s, err := store.Open(filepath.Join(t.TempDir(), store.DefaultDBName))
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.AddSkippedDeposit(&store.SkippedDeposit{Txid: "txid2", Height: 101, Amount: 300, Reason: "below-min-deposit"}))
+	require.NoError(t, s.AddSkippedDeposit(&store.SkippedDeposit{Txid: "txid1", Height: 100, Amount: 500, Reason: "below-min-deposit"}))
+	// a rescan replaces the record of the transaction
+	require.NoError(t, s.AddSkippedDeposit(&store.SkippedDeposit{Txid: "txid1", Height: 100, Amount: 500, Reason: "script-type"}))
+
+	deposits, err := s.SkippedDeposits()
+	require.NoError(t, err)
+	require.Len(t, deposits, 2)
+	require.Equal(t, "txid1", deposits[0].Txid)
+	require.Equal(t, "script-type", deposits[0].Reason)
+
+	require.NoError(t, s.RemoveSkippedDeposit("txid1"))
+	deposits, err = s.SkippedDeposits()
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	require.Equal(t, "txid2", deposits[0].Txid)
+}
@@ -1,16 +1,29 @@
 package app
 
 import (
-	"encoding/hex"
+	"time"
 
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
-	"github.com/btcsuite/btcd/txscript"
+	"github.com/sideprotocol/shuttler/app/store"
 	btcbridge "github.com/sideprotocol/side/x/btcbridge/types"
 	"go.uber.org/zap"
 )
 
+const (
+	// Attempts to submit block headers on transient failures
+	maxSubmitRetries = 3
+	submitRetryDelay = 2 * time.Second
+
+	// Max blocks scanned for vault transactions in one call of the scanner,
+	// so that a catch-up or a rescan doesn't block the main loop
+	maxScanBlocks = 20
+
+	// Confirmations of a block before it's scanned, if not set in the light client params
+	defaultConfirmations = 6
+)
+
 // Send Submit Block Header Request
 func (a *State) SendSubmitBlockHeaderRequest(headers []*btcbridge.BlockHeader) error {
 	msg := &btcbridge.MsgSubmitBlockHeaderRequest{
@@ -23,6 +36,15 @@ func (a *State) SendSubmitBlockHeaderRequest(headers []*btcbridge.BlockHeader) e
 // Sync the light client with the bitcoin network
 func (a *State) FastSyncLightClient() {
 
+	if err := a.halted(); err != nil {
+		a.Log.Error("Header relay halted", zap.Error(err))
+		return
+	}
+	if a.isRelayPaused() {
+		a.Log.Info("Header relay paused")
+		return
+	}
+
 	// Get the current height from the sidechain
 	lightClientTip, err := a.QueryChainTip()
 	if err != nil {
@@ -31,7 +53,49 @@ func (a *State) FastSyncLightClient() {
 	}
 
 	a.Log.Info("Start syncing light client", zap.Uint64("height", lightClientTip.Height), zap.String("hash", lightClientTip.Hash))
+	lightClientTipHeight.Set(float64(lightClientTip.Height))
 
+	// Resume from the last relayed header if the light client is still on it,
+	// so that forks are detected from the very first block
+	last, err := a.store.LastHeader()
+	if err != nil {
+		a.Log.Error("Failed to load the last relayed header", zap.Error(err))
+		return
+	}
+	if last != nil && last.Hash == lightClientTip.Hash {
+		hash, err := chainhash.NewHashFromStr(last.Hash)
+		if err != nil {
+			a.Log.Error("Failed to process block hash", zap.Error(err))
+			return
+		}
+		block, err := a.rpc.GetBlockHeaderVerbose(hash)
+		if err != nil {
+			a.Log.Error("Failed to process block", zap.Error(err))
+			return
+		}
+		a.lastBitcoinBlock = block
+		a.Log.Info("Resume from the last relayed header", zap.Int32("height", last.Height), zap.String("hash", last.Hash))
+	}
+
+	// Blocks are submitted in batches, the pending ones are flushed on return
+	pending := []*btcjson.GetBlockHeaderVerboseResult{}
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		err := a.SubmitBlock(pending)
+		pending = []*btcjson.GetBlockHeaderVerboseResult{}
+		return err
+	}
+	defer func() {
+		if err := flush(); err != nil {
+			a.setSynced(false)
+			a.Log.Error("Failed to submit blocks", zap.Error(err))
+		}
+	}()
+
+	// The last block queued for submission
+	prev := a.lastBitcoinBlock
 	currentHeight := lightClientTip.Height + 1
 
 	for {
@@ -42,7 +106,7 @@ func (a *State) FastSyncLightClient() {
 		}
 
 		if a.lastBitcoinBlock != nil && hash.String() == a.lastBitcoinBlock.Hash {
-			a.synced = true
+			a.setSynced(true)
 			a.Log.Info("Reached the last block")
 			return
 		}
@@ -53,17 +117,37 @@ func (a *State) FastSyncLightClient() {
 			return
 		}
 
-		if a.lastBitcoinBlock != nil && a.lastBitcoinBlock.Hash != block.PreviousHash {
-			a.Log.Error("There must be a forked branch", zap.String("lasthash", a.lastBitcoinBlock.Hash), zap.String("previoushash", block.PreviousHash))
-			return
+		// The block must extend the light client chain, otherwise there is a forked branch
+		lastHash := lightClientTip.Hash
+		if prev != nil {
+			lastHash = prev.Hash
 		}
 
-		a.lastBitcoinBlock = block
+		if lastHash != block.PreviousHash {
+			a.Log.Error("There must be a forked branch", zap.String("lasthash", lastHash), zap.String("previoushash", block.PreviousHash))
 
-		// a.Log.Info("Submit Block to Sidechain", zap.String("hash", block.Hash))
-		// Submit block to sidechain
-		a.SubmitBlock([]*btcjson.GetBlockHeaderVerboseResult{block})
-		a.Log.Debug("Block submitted",
+			// the light client must have the pending blocks to find the common ancestor
+			if err := flush(); err != nil {
+				a.Log.Error("Failed to submit blocks", zap.Error(err))
+				return
+			}
+			if err := a.handleReorg(block); err != nil {
+				a.Log.Error("Failed to resolve the forked branch", zap.Error(err))
+				return
+			}
+		} else {
+			// a.Log.Info("Submit Block to Sidechain", zap.String("hash", block.Hash))
+			// Submit blocks to sidechain once the batch is full
+			pending = append(pending, block)
+			if len(pending) >= a.headerBatchSize() {
+				if err := flush(); err != nil {
+					a.Log.Error("Failed to submit blocks", zap.Error(err))
+					return
+				}
+			}
+		}
+		prev = block
+		a.Log.Debug("Block queued",
 			zap.Int32("Height", block.Height),
 			zap.String("PreviousBlockHash", block.PreviousHash),
 			// zap.String("MerkleRoot", header.MerkleRoot),
@@ -80,7 +164,7 @@ func (a *State) FastSyncLightClient() {
 			return
 		}
 		if besthash.String() == block.Hash {
-			a.synced = true
+			a.setSynced(true)
 			a.Log.Info("Reached the best block")
 			return
 		}
@@ -97,8 +181,19 @@ func (a *State) OnNewBtcBlock(c []string) {
 		return
 	}
 
-	if !a.synced {
-		a.Log.Info("Not synced yet, skipping block", zap.String("hash", hash.String()))
+	if err := a.halted(); err != nil {
+		a.Log.Error("Header relay halted, skipping block", zap.String("hash", hash.String()), zap.Error(err))
+		return
+	}
+	if a.isRelayPaused() {
+		a.Log.Info("Header relay paused, skipping block", zap.String("hash", hash.String()))
+		return
+	}
+
+	// Catch up again, e.g. after a failed submission
+	if !a.isSynced() {
+		a.Log.Info("Not synced yet, catching up", zap.String("hash", hash.String()))
+		a.FastSyncLightClient()
 		return
 	}
 
@@ -108,6 +203,7 @@ func (a *State) OnNewBtcBlock(c []string) {
 		a.Log.Error("Failed to process block", zap.Error(err))
 		return
 	}
+	bitcoinTipHeight.Set(float64(block.Height))
 
 	// it's the same block
 	if a.lastBitcoinBlock.Hash == block.Hash {
@@ -121,30 +217,38 @@ func (a *State) OnNewBtcBlock(c []string) {
 		a.Log.Info("Replace the last header with the new one", zap.Int32("behind", block.Height-a.lastBitcoinBlock.Height))
 		a.Log.Info("===================================================================")
 
+		prev := a.lastBitcoinBlock
 		newBlocks := []*btcjson.GetBlockHeaderVerboseResult{}
-		for i := a.lastBitcoinBlock.Height + 1; i < block.Height; i++ {
+		for i := a.lastBitcoinBlock.Height + 1; i <= block.Height; i++ {
 			hash, err := client.GetBlockHash(int64(i))
 			if err != nil {
 				a.Log.Error("Failed to process block hash", zap.Error(err))
 				return
 			}
 
-			block, err := client.GetBlockHeaderVerbose(hash)
+			b, err := client.GetBlockHeaderVerbose(hash)
 			if err != nil {
 				a.Log.Error("Failed to process block", zap.Error(err))
 				return
 			}
 
-			if a.lastBitcoinBlock.Hash != block.PreviousHash {
-				a.Log.Error("There must be a forked branch", zap.String("lasthash", a.lastBitcoinBlock.Hash), zap.String("previoushash", block.PreviousHash))
+			if prev.Hash != b.PreviousHash {
+				a.Log.Error("There must be a forked branch", zap.String("lasthash", prev.Hash), zap.String("previoushash", b.PreviousHash))
+
+				// the replacement branch up to the new block covers the gap as well
+				if err := a.handleReorg(block); err != nil {
+					a.Log.Error("Failed to resolve the forked branch", zap.Error(err))
+				}
 				return
 			}
 
-			a.lastBitcoinBlock = block
-			newBlocks = append(newBlocks, block)
+			prev = b
+			newBlocks = append(newBlocks, b)
 		}
 
-		a.SubmitBlock(newBlocks)
+		if err := a.SubmitBlock(newBlocks); err != nil {
+			a.Log.Error("Failed to submit blocks", zap.Error(err))
+		}
 		return
 	}
 
@@ -159,95 +263,229 @@ func (a *State) OnNewBtcBlock(c []string) {
 			zap.String("new.previoushash", block.PreviousHash),
 		)
 
-		// only check the last one block for now
-		// found the the common ancestor, and continue from there.
-		if a.lastBitcoinBlock.PreviousHash == block.PreviousHash {
-			a.Log.Info("===================================================================")
-			a.Log.Info("Replace the last header with the new one", zap.Int32("height", block.Height))
-			a.Log.Info("===================================================================")
-			a.lastBitcoinBlock = block
-
-			a.SubmitBlock([]*btcjson.GetBlockHeaderVerboseResult{block})
-			return
+		// walk back to the common ancestor, and replace the branch from there.
+		if err := a.handleReorg(block); err != nil {
+			a.Log.Error("Failed to resolve the forked branch", zap.Error(err))
 		}
-
-		a.Log.Error("Forked branch detected, but no common ancestor found in the last 10 blocks")
 		return
 	}
 
-	a.SubmitBlock([]*btcjson.GetBlockHeaderVerboseResult{block})
-
-	a.lastBitcoinBlock = block
-
+	if err := a.SubmitBlock([]*btcjson.GetBlockHeaderVerboseResult{block}); err != nil {
+		a.Log.Error("Failed to submit block", zap.Error(err))
+	}
 }
 
-func (a *State) SubmitBlock(blocks []*btcjson.GetBlockHeaderVerboseResult) {
-	// Submit block to the sidechain
-	for i, block := range blocks {
-		a.Log.Debug("Block submitted",
-			zap.Int("i", i),
-			zap.String("P", block.PreviousHash),
-			zap.Int32("Height", block.Height),
-			zap.Int32("v", block.Version),
-		)
-		a.Log.Debug("Block submitted",
-			zap.String("H", block.Hash),
-			zap.String("bits", block.Bits),
-		)
+// Submit blocks to the sidechain in batches
+// The last bitcoin block is advanced once a batch is accepted by the sidechain
+func (a *State) SubmitBlock(blocks []*btcjson.GetBlockHeaderVerboseResult) error {
+	for _, batch := range splitHeaderBatches(blocks, a.headerBatchSize(), a.headerBatchBytes()) {
+		headers := make([]*btcbridge.BlockHeader, len(batch))
+		for i, block := range batch {
+			a.Log.Debug("Block submitted",
+				zap.Int("i", i),
+				zap.String("P", block.PreviousHash),
+				zap.Int32("Height", block.Height),
+				zap.Int32("v", block.Version),
+			)
+			a.Log.Debug("Block submitted",
+				zap.String("H", block.Hash),
+				zap.String("bits", block.Bits),
+			)
+
+			headers[i] = toBlockHeader(block)
+		}
 
-		b := &btcbridge.BlockHeader{
-			PreviousBlockHash: block.PreviousHash,
-			Hash:              block.Hash,
-			Height:            uint64(block.Height),
-			Version:           uint64(block.Version),
-			MerkleRoot:        block.MerkleRoot,
-			Time:              uint64(block.Time),
-			Bits:              block.Bits,
-			Nonce:             uint64(block.Nonce),
-			// Ntx:               uint64(block.),
+		// Make sure the bitcoin nodes agree on the blocks
+		if err := a.verifyQuorum(batch); err != nil {
+			return err
 		}
 
 		// Submit block to sidechain
-		err := a.SendSubmitBlockHeaderRequest([]*btcbridge.BlockHeader{b})
+		if err := a.submitHeaders(headers, true); err != nil {
+			return err
+		}
+
+		last := batch[len(batch)-1]
+		a.lastBitcoinBlock = last
+		headersSubmitted.Add(float64(len(batch)))
+		lightClientTipHeight.Set(float64(last.Height))
+		a.Log.Info("Blocks submitted", zap.Int("count", len(batch)), zap.Int32("from", batch[0].Height), zap.Int32("to", last.Height))
+
+		err := a.store.SetLastHeader(&store.Header{Hash: last.Hash, Height: last.Height})
 		if err != nil {
-			a.Log.Error("Failed to submit block", zap.Error(err))
-			panic(err)
+			a.Log.Error("Failed to save the last relayed header", zap.Error(err))
 		}
 
-		a.ScanVaultTx(block.Height)
+		if err = a.ScanVaultTx(); err != nil {
+			a.Log.Error("Failed to scan vault transactions", zap.Error(err))
+		}
 	}
+	return nil
 }
 
-// Scan the transanctions in the block
-// Check if the transaction is a deposit/withdraw transaction
-// If it is, submit the transaction to the sidechain
-// This block should be confirmed
-func (a *State) ScanVaultTx(current int32) error {
+// Submit the headers to the sidechain
+// Transient failures are retried, headers already relayed are skipped,
+// and header relay is halted on any other failure.
+// A batch with headers already relayed is split to relay the remaining ones, unless split is false,
+// e.g. for a reorg branch, which the light client only accepts as a whole.
+func (a *State) submitHeaders(headers []*btcbridge.BlockHeader, split bool) error {
+	err := retryTransient(maxSubmitRetries, submitRetryDelay, func() error {
+		return a.SendSubmitBlockHeaderRequest(headers)
+	})
+
+	switch {
+	case err == nil:
+		return nil
+	case IsTxErrorKind(err, ErrKindDuplicate):
+		// Some headers have been relayed already, e.g. by another relayer,
+		// submit the batch one by one to relay the remaining ones
+		if split && len(headers) > 1 {
+			for _, h := range headers {
+				if err := a.submitHeaders([]*btcbridge.BlockHeader{h}, true); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		a.Log.Warn("Block already submitted", zap.Uint64("height", headers[0].Height), zap.String("hash", headers[0].Hash), zap.Int("count", len(headers)))
+		return nil
+	case IsTxErrorKind(err, ErrKindTransient):
+		return err
+	default:
+		a.halt(err)
+		a.Log.Error("Halting header relay", zap.Error(err))
+		return err
+	}
+}
 
-	height := current - a.params.Confirmations
-	if height == current {
-		height = current - 6
+// Max number of block headers in one submission
+func (a *State) headerBatchSize() int {
+	if a.Config.Side.HeaderBatchSize > 0 {
+		return a.Config.Side.HeaderBatchSize
 	}
+	return DefaultHeaderBatchSize
+}
+
+// Max estimated size of block headers in one submission
+func (a *State) headerBatchBytes() int {
+	if a.Config.Side.HeaderBatchBytes > 0 {
+		return a.Config.Side.HeaderBatchBytes
+	}
+	return DefaultHeaderBatchBytes
+}
+
+// Estimate the encoded size of a block header in the submission message
+// Hashes are hex encoded strings, and each field takes a few bytes of overhead
+func estimateHeaderSize(block *btcjson.GetBlockHeaderVerboseResult) int {
+	return len(block.Hash) + len(block.PreviousHash) + len(block.MerkleRoot) + len(block.Bits) + 64
+}
+
+// Split the blocks into batches bounded by the number of headers and their estimated size
+// A batch always contains at least one block
+func splitHeaderBatches(blocks []*btcjson.GetBlockHeaderVerboseResult, maxCount, maxBytes int) [][]*btcjson.GetBlockHeaderVerboseResult {
+	batches := [][]*btcjson.GetBlockHeaderVerboseResult{}
+	batch := []*btcjson.GetBlockHeaderVerboseResult{}
+	size := 0
+	for _, block := range blocks {
+		s := estimateHeaderSize(block)
+		if len(batch) > 0 && (len(batch) >= maxCount || size+s > maxBytes) {
+			batches = append(batches, batch)
+			batch = []*btcjson.GetBlockHeaderVerboseResult{}
+			size = 0
+		}
+		batch = append(batch, block)
+		size += s
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// Convert the bitcoind block header to the light client block header
+func toBlockHeader(block *btcjson.GetBlockHeaderVerboseResult) *btcbridge.BlockHeader {
+	return &btcbridge.BlockHeader{
+		PreviousBlockHash: block.PreviousHash,
+		Hash:              block.Hash,
+		Height:            uint64(block.Height),
+		Version:           uint64(block.Version),
+		MerkleRoot:        block.MerkleRoot,
+		Time:              uint64(block.Time),
+		Bits:              block.Bits,
+		Nonce:             uint64(block.Nonce),
+		// Ntx:               uint64(block.),
+	}
+}
+
+// Scan the transanctions in the block
+// ScanVaultTx scans the blocks confirmed on the light client for deposit/withdraw transactions,
+// and submits them to the sidechain.
+// The next height to scan is tracked in the store independently of the header relay,
+// so every block is scanned once it reaches the confirmation depth, also after a catch-up or a restart.
+// At most maxScanBlocks are scanned per call, the next calls continue from the cursor.
+func (a *State) ScanVaultTx() error {
+
+	// Refresh the params, the vaults and confirmations may have changed
+	params, err := a.QueryParams()
+	if err != nil {
+		return err
+	}
+	a.params = params
 
-	a.Log.Info("Scanning block", zap.Int32("height", height), zap.Int32("current", current))
-	// _, bestHeight, err := a.()
-	// if err != nil {
-	// 	return err
-	// }
 	lightClientTip, err := a.QueryChainTip()
 	if err != nil {
-		a.Log.Error("Failed to query light client chain tip", zap.Error(err))
-		return nil
+		return err
 	}
 
-	// check if the block is already confirmed
-	// if not, return, because sidechain is instant finality,
-	// have to wait for the block to be confirmed
-	if height < int32(lightClientTip.Height)-a.params.Confirmations {
+	// The sidechain has instant finality,
+	// so only the blocks with enough confirmations on the light client are scanned
+	confirmed := confirmedHeight(int32(lightClientTip.Height), a.params.Confirmations)
+	if confirmed <= 0 {
 		return nil
 	}
 
-	// process block
+	scanned, ok, err := a.store.ScannedHeight()
+	if err != nil {
+		return err
+	}
+
+	next := nextScanHeight(scanned, ok, confirmed)
+	last := min(confirmed, next+maxScanBlocks-1)
+	for h := next; h <= last; h++ {
+		a.Log.Info("Scanning block", zap.Int32("height", h), zap.Int32("confirmed", confirmed))
+		if err := a.scanBlock(h, nil); err != nil {
+			return err
+		}
+		if err := a.store.SetScannedHeight(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get the last confirmed height of the light client tip
+// The blocks at the tip are never confirmed, the default confirmations apply when the param is not set.
+func confirmedHeight(tip, confirmations int32) int32 {
+	if confirmations <= 0 {
+		confirmations = defaultConfirmations
+	}
+	return tip - confirmations
+}
+
+// Get the next height to scan after the scanned height
+// Starts from the last confirmed block if nothing has been scanned yet.
+func nextScanHeight(scanned int32, ok bool, confirmed int32) int32 {
+	if !ok {
+		return confirmed
+	}
+	return scanned + 1
+}
+
+// Scan the transactions of the block at the given height
+// Transactions already submitted to the sidechain are skipped.
+// The vault transactions found are added to the report if not nil.
+func (a *State) scanBlock(height int32, report *ScanReport) error {
+
 	blockhash, err := a.rpc.GetBlockHash(int64(height))
 	if err != nil {
 		return err
@@ -263,41 +501,115 @@ func (a *State) ScanVaultTx(current int32) error {
 		// Submit the transaction to the sidechain
 		a.Log.Debug("Checking if the transaction is a withdraw transaction", zap.Int("index", i), zap.String("tx", tx.Hash().String()))
 
-		if len(tx.MsgTx().TxIn) > 0 && len(tx.MsgTx().TxIn[0].Witness) == 2 {
-			senderPubKey := tx.MsgTx().TxIn[0].Witness[1]
+		kind, outputs := a.vaultTxKind(tx)
 
-			vault := btcbridge.SelectVaultByPubKey(a.params.Vaults, hex.EncodeToString(senderPubKey))
-			if vault != nil {
-				err = a.SubmitWithdrawalTx(blockhash, tx, uBlock.Transactions())
-				if err != nil {
-					return err
-				}
+		if kind == store.Withdrawal {
+			// The outputs paying back to the vaults are the change of the withdrawal, not deposits
+			for _, out := range outputs {
+				a.Log.Debug("Withdrawal change output", zap.String("tx", tx.Hash().String()), zap.Int("index", out.Index), zap.String("vault", out.Vault), zap.Int64("amount", out.Amount))
+			}
+			err = a.submitVaultTx(report, store.Withdrawal, height, tx, func() error {
+				return a.SubmitWithdrawalTx(blockhash, tx, uBlock.Transactions())
+			})
+			if err != nil {
+				return err
 			}
+			continue
 		}
 
 		// check if the transaction is a deposit transaction
-		for _, txOut := range tx.MsgTx().TxOut {
-
-			pkScript, err := txscript.ParsePkScript(txOut.PkScript)
-			if err != nil {
-				continue
+		// A deposit paying several vault outputs is submitted once
+		if kind != store.Deposit {
+			continue
+		}
+		for _, out := range outputs {
+			a.Log.Info("Deposit output", zap.String("tx", tx.Hash().String()), zap.Int("index", out.Index), zap.String("vault", out.Vault), zap.Int64("amount", out.Amount))
+		}
+		err = a.submitVaultTx(report, store.Deposit, height, tx, func() error {
+			if err := a.checkDepositAmount(tx, outputs); err != nil {
+				return err
 			}
-			addr, err := pkScript.Address(a.GetChainCfg())
-			if err != nil {
-				continue
+			if err := a.SubmitDepositTx(blockhash, tx, uBlock.Transactions()); err != nil {
+				return err
 			}
-
-			vault := btcbridge.SelectVaultByBitcoinAddress(a.params.Vaults, addr.String())
-			if vault == nil {
-				continue
+			for _, out := range outputs {
+				depositAmount.WithLabelValues(out.Vault).Add(float64(out.Amount))
 			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			err = a.SubmitDepositTx(blockhash, tx, uBlock.Transactions())
-			if err != nil {
-				return err
-			}
+// Get the kind of the vault transaction, empty if it's not one, with its outputs paying to the vaults
+// A transaction spending from a vault is a withdrawal, even if it pays change to any vault.
+func (a *State) vaultTxKind(tx *btcutil.Tx) (store.TxKind, []vaultOutput) {
+	outputs := a.vaultOutputs(tx)
+	if withdrawalVault(a.params.Vaults, tx) != nil {
+		return store.Withdrawal, outputs
+	}
+	if len(outputs) > 0 {
+		return store.Deposit, outputs
+	}
+	return "", outputs
+}
+
+// Submit the vault transaction once, and record it in the store
+func (a *State) submitVaultTx(report *ScanReport, kind store.TxKind, height int32, tx *btcutil.Tx, submit func() error) error {
+	txid := tx.Hash().String()
+
+	submitted, err := a.store.IsSubmitted(kind, txid)
+	if err != nil {
+		return err
+	}
+	if submitted {
+		a.Log.Debug("Transaction already submitted", zap.String("kind", string(kind)), zap.String("txid", txid))
+		report.add(kind, height, txid, ScanResultKnown)
+		return nil
+	}
+
+	// A rescan goes over blocks whose transactions the btcbridge module may already have
+	if report != nil {
+		known, err := a.knownByBridge(kind, tx)
+		if err != nil {
+			return err
+		}
+		if known {
+			a.Log.Debug("Transaction known by the btcbridge module", zap.String("kind", string(kind)), zap.String("txid", txid))
+			report.add(kind, height, txid, ScanResultKnown)
+			return a.store.MarkSubmitted(kind, txid, height)
 		}
+	}
 
+	err = submit()
+	if skip := depositSkip(err); skip != nil {
+		a.Log.Warn("Deposit skipped", zap.String("txid", txid), zap.String("reason", skip.Reason), zap.String("detail", skip.Detail))
+		depositsSkipped.WithLabelValues(skip.Reason).Inc()
+		report.skip(kind, height, txid, skip.Reason)
+		return a.recordSkippedDeposit(height, tx, skip)
 	}
-	return nil
+
+	switch {
+	case err == nil:
+		vaultTxSubmitted.WithLabelValues(string(kind)).Inc()
+		report.add(kind, height, txid, ScanResultSubmitted)
+	case IsTxErrorKind(err, ErrKindDuplicate):
+		a.Log.Warn("Transaction already submitted", zap.String("kind", string(kind)), zap.String("txid", txid))
+		report.add(kind, height, txid, ScanResultKnown)
+	case IsTxErrorKind(err, ErrKindPermanent):
+		// Skip the transaction, it would never be accepted
+		a.Log.Error("Transaction rejected, skipping", zap.String("kind", string(kind)), zap.String("txid", txid), zap.Error(err))
+		vaultTxFailed.WithLabelValues(string(kind)).Inc()
+		report.add(kind, height, txid, ScanResultRejected)
+		return nil
+	default:
+		// Abort the block, it will be scanned again
+		vaultTxFailed.WithLabelValues(string(kind)).Inc()
+		return err
+	}
+
+	return a.store.MarkSubmitted(kind, txid, height)
 }
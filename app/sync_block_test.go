@@ -0,0 +1,290 @@
+package app
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/sideprotocol/shuttler/app/store"
+	btcbridge "github.com/sideprotocol/side/x/btcbridge/types"
+	"google.golang.org/grpc"
+)
+
+func Test_SplitHeaderBatches(t *testing.T) {
+
+	blocks := []*btcjson.GetBlockHeaderVerboseResult{}
+	for i := 0; i < 45; i++ {
+		blocks = append(blocks, &btcjson.GetBlockHeaderVerboseResult{
+			Hash:         fmt.Sprintf("%064x", i+1),
+			PreviousHash: fmt.Sprintf("%064x", i),
+			MerkleRoot:   fmt.Sprintf("%064x", i),
+			Bits:         "1d00ffff",
+			Height:       int32(i + 1),
+		})
+	}
+	size := estimateHeaderSize(blocks[0])
+
+	// bounded by count
+	batches := splitHeaderBatches(blocks, 20, 1024*1024)
+	if len(batches) != 3 || len(batches[0]) != 20 || len(batches[2]) != 5 {
+		t.Errorf("Expected batches of 20, 20, 5, got %d batches", len(batches))
+	}
+
+	// bounded by size
+	batches = splitHeaderBatches(blocks, 100, size*10)
+	if len(batches) != 5 || len(batches[0]) != 10 {
+		t.Errorf("Expected 5 batches of 10, got %d batches", len(batches))
+	}
+
+	// a header larger than the max size is still submitted
+	batches = splitHeaderBatches(blocks[:2], 100, 1)
+	if len(batches) != 2 {
+		t.Errorf("Expected 2 batches, got %d", len(batches))
+	}
+
+	// the order of the blocks is kept
+	batches = splitHeaderBatches(blocks, 7, 1024*1024)
+	height := int32(0)
+	for _, batch := range batches {
+		for _, block := range batch {
+			if block.Height != height+1 {
+				t.Errorf("Expected height %d, got %d", height+1, block.Height)
+			}
+			height = block.Height
+		}
+	}
+}
+
+func Test_NextScanHeight(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		scanned   int32
+		ok        bool
+		confirmed int32
+		expected  int32
+	}{
+		{"first run", 0, false, 100, 100},
+		{"scanned height 0", 0, true, 100, 1},
+		{"resume", 90, true, 100, 91},
+		{"up to date", 100, true, 100, 101},
+	}
+	for _, tt := range tests {
+		if next := nextScanHeight(tt.scanned, tt.ok, tt.confirmed); next != tt.expected {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.expected, next)
+		}
+	}
+}
+
+func Test_ConfirmedHeight(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		tip           int32
+		confirmations int32
+		expected      int32
+	}{
+		{"confirmations", 100, 3, 97},
+		{"unset confirmations", 100, 0, 100 - defaultConfirmations},
+		{"negative confirmations", 100, -1, 100 - defaultConfirmations},
+	}
+	for _, tt := range tests {
+		if confirmed := confirmedHeight(tt.tip, tt.confirmations); confirmed != tt.expected {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.expected, confirmed)
+		}
+	}
+}
+
+func Test_VaultTxKind(t *testing.T) {
+
+	a := mockState(t)
+
+	vaultKey, vault := mockP2WPKH(t)
+	_, vault2 := mockP2WPKH(t)
+	userKey, user := mockP2WPKH(t)
+	a.params = &btcbridge.Params{Vaults: []*btcbridge.Vault{
+		{Address: vault.EncodeAddress(), PubKey: hex.EncodeToString(vaultKey.SerializeCompressed())},
+		{Address: vault2.EncodeAddress()},
+	}}
+	vaultScript, _ := txscript.PayToAddrScript(vault)
+	vault2Script, _ := txscript.PayToAddrScript(vault2)
+	userScript, _ := txscript.PayToAddrScript(user)
+
+	// spend by a P2WPKH witness of the key, to the outputs
+	spend := func(key []byte, outputs ...[]byte) *btcutil.Tx {
+		tx := wire.NewMsgTx(2)
+		txIn := wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{0x01}, 0), nil, nil)
+		txIn.Witness = wire.TxWitness{make([]byte, 71), key}
+		tx.AddTxIn(txIn)
+		for _, pkScript := range outputs {
+			tx.AddTxOut(wire.NewTxOut(1000, pkScript))
+		}
+		return btcutil.NewTx(tx)
+	}
+
+	cases := []struct {
+		name    string
+		tx      *btcutil.Tx
+		kind    store.TxKind
+		outputs int
+	}{
+		{"deposit", spend(userKey.SerializeCompressed(), vaultScript), store.Deposit, 1},
+		{"deposit to both vaults", spend(userKey.SerializeCompressed(), vaultScript, vault2Script), store.Deposit, 2},
+		{"withdrawal with change", spend(vaultKey.SerializeCompressed(), userScript, vaultScript), store.Withdrawal, 1},
+		{"withdrawal with change to a second vault", spend(vaultKey.SerializeCompressed(), userScript, vault2Script), store.Withdrawal, 1},
+		{"other", spend(userKey.SerializeCompressed(), userScript), "", 0},
+	}
+	for _, c := range cases {
+		kind, outputs := a.vaultTxKind(c.tx)
+		if kind != c.kind || len(outputs) != c.outputs {
+			t.Errorf("%s: expected %q with %d vault outputs, got %q with %d", c.name, c.kind, c.outputs, kind, len(outputs))
+		}
+	}
+}
+
+// btcbridge query client with a fixed light client chain, params, signing requests and UTXOs
+type mockQueryClient struct {
+	btcbridge.QueryClient
+	queries  int
+	headers  []*btcbridge.BlockHeader
+	requests []*btcbridge.BitcoinSigningRequest
+	utxos    []*btcbridge.UTXO
+	params   btcbridge.Params
+	// error returned by the signing request queries
+	err error
+}
+
+// Light client chain of the block hashes
+func mockHeaders(hashes []chainhash.Hash) []*btcbridge.BlockHeader {
+	headers := make([]*btcbridge.BlockHeader, len(hashes))
+	for i := range hashes {
+		headers[i] = &btcbridge.BlockHeader{Hash: hashes[i].String(), Height: uint64(i)}
+	}
+	return headers
+}
+
+func (m *mockQueryClient) QueryChainTip(context.Context, *btcbridge.QueryChainTipRequest, ...grpc.CallOption) (*btcbridge.QueryChainTipResponse, error) {
+	tip := m.headers[len(m.headers)-1]
+	return &btcbridge.QueryChainTipResponse{Hash: tip.Hash, Height: tip.Height}, nil
+}
+
+func (m *mockQueryClient) QueryBlockHeaderByHeight(_ context.Context, in *btcbridge.QueryBlockHeaderByHeightRequest, _ ...grpc.CallOption) (*btcbridge.QueryBlockHeaderByHeightResponse, error) {
+	if in.Height >= uint64(len(m.headers)) {
+		return nil, fmt.Errorf("block header %d not found", in.Height)
+	}
+	return &btcbridge.QueryBlockHeaderByHeightResponse{BlockHeader: m.headers[in.Height]}, nil
+}
+
+func (m *mockQueryClient) QueryBlockHeaderByHash(_ context.Context, in *btcbridge.QueryBlockHeaderByHashRequest, _ ...grpc.CallOption) (*btcbridge.QueryBlockHeaderByHashResponse, error) {
+	for _, h := range m.headers {
+		if h.Hash == in.Hash {
+			return &btcbridge.QueryBlockHeaderByHashResponse{BlockHeader: h}, nil
+		}
+	}
+	return nil, fmt.Errorf("block header %s not found", in.Hash)
+}
+
+func (m *mockQueryClient) QueryParams(context.Context, *btcbridge.QueryParamsRequest, ...grpc.CallOption) (*btcbridge.QueryParamsResponse, error) {
+	return &btcbridge.QueryParamsResponse{Params: m.params}, nil
+}
+
+func (m *mockQueryClient) QuerySigningRequest(_ context.Context, in *btcbridge.QuerySigningRequestRequest, _ ...grpc.CallOption) (*btcbridge.QuerySigningRequestResponse, error) {
+	m.queries++
+	if m.err != nil {
+		return nil, m.err
+	}
+	res := &btcbridge.QuerySigningRequestResponse{}
+	for _, r := range m.requests {
+		if r.Status == in.Status {
+			res.Requests = append(res.Requests, r)
+		}
+	}
+	return res, nil
+}
+
+func (m *mockQueryClient) QueryUTXOsByAddress(_ context.Context, in *btcbridge.QueryUTXOsByAddressRequest, _ ...grpc.CallOption) (*btcbridge.QueryUTXOsByAddressResponse, error) {
+	m.queries++
+	res := &btcbridge.QueryUTXOsByAddressResponse{}
+	for _, u := range m.utxos {
+		if u.Address == in.Address {
+			res.Utxos = append(res.Utxos, u)
+		}
+	}
+	return res, nil
+}
+
+func Test_SubmitVaultTx(t *testing.T) {
+
+	s, err := store.Open(filepath.Join(t.TempDir(), store.DefaultDBName))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer s.Close()
+
+	a := mockState(t)
+	a.store = s
+
+	_, vault := mockP2WPKH(t)
+	a.params = &btcbridge.Params{Vaults: []*btcbridge.Vault{{Address: vault.EncodeAddress()}}}
+	vaultScript, _ := txscript.PayToAddrScript(vault)
+
+	newTx := func(lockTime uint32) *btcutil.Tx {
+		tx := wire.NewMsgTx(2)
+		tx.LockTime = lockTime
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{0x01}, 0), nil, nil))
+		tx.AddTxOut(wire.NewTxOut(1000, vaultScript))
+		return btcutil.NewTx(tx)
+	}
+	deposit, withdrawal, unknown, scanned := newTx(1), newTx(2), newTx(3), newTx(4)
+
+	client := &mockQueryClient{
+		requests: []*btcbridge.BitcoinSigningRequest{{Txid: withdrawal.Hash().String(), Status: btcbridge.SigningStatus_SIGNING_STATUS_CONFIRMED}},
+		utxos:    []*btcbridge.UTXO{{Txid: deposit.Hash().String(), Address: vault.EncodeAddress()}},
+	}
+	a.grpcQueryClient = client
+
+	cases := []struct {
+		name      string
+		kind      store.TxKind
+		tx        *btcutil.Tx
+		report    *ScanReport
+		submitted bool
+		result    string
+	}{
+		{"deposit known by the bridge", store.Deposit, deposit, &ScanReport{}, false, ScanResultKnown},
+		{"withdrawal known by the bridge", store.Withdrawal, withdrawal, &ScanReport{}, false, ScanResultKnown},
+		{"unknown deposit", store.Deposit, unknown, &ScanReport{}, true, ScanResultSubmitted},
+		{"deposit known by the relayer", store.Deposit, deposit, &ScanReport{}, false, ScanResultKnown},
+		{"scan of a new block", store.Deposit, scanned, nil, true, ""},
+	}
+	for _, c := range cases {
+		submitted := false
+		queries := client.queries
+		err := a.submitVaultTx(c.report, c.kind, 1, c.tx, func() error {
+			submitted = true
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if submitted != c.submitted {
+			t.Errorf("%s: expected submitted %v, got %v", c.name, c.submitted, submitted)
+		}
+		if c.report == nil {
+			if client.queries != queries {
+				t.Errorf("%s: expected no btcbridge query", c.name)
+			}
+			continue
+		}
+		if len(c.report.Txs) != 1 || c.report.Txs[0].Result != c.result {
+			t.Errorf("%s: expected %s, got %+v", c.name, c.result, c.report.Txs)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package app
 import (
 	"bytes"
 	"encoding/base64"
-	"fmt"
 
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -14,6 +13,7 @@ import (
 )
 
 // Submit Deposit Transaction to Sidechain
+// The inputs considered by the sender strategy must be of the allowed script types.
 func (a *State) SubmitDepositTx(blockhash *chainhash.Hash, tx *btcutil.Tx, txs []*btcutil.Tx) error {
 
 	// Check if the transaction has at least 1 input
@@ -22,31 +22,75 @@ func (a *State) SubmitDepositTx(blockhash *chainhash.Hash, tx *btcutil.Tx, txs [
 		return nil
 	}
 
-	// Get the previous transaction
-	// Use 0th input as the sender
-	txIn := tx.MsgTx().TxIn[0]
-	prevTxHash := txIn.PreviousOutPoint.Hash
-	prevTx, err := a.rpc.GetRawTransaction(&prevTxHash)
+	sender, err := a.resolveDepositSender(tx, txs)
 	if err != nil {
 		return err
 	}
+	if err := a.checkDepositScriptTypes(tx, sender); err != nil {
+		return err
+	}
 
-	// extract the sender address from the previous transaction
-	// Get the address from the previous transaction output
-	prevVout := prevTx.MsgTx().TxOut[txIn.PreviousOutPoint.Index]
-	_, senderAddrs, _, err := txscript.ExtractPkScriptAddrs(prevVout.PkScript, a.GetChainCfg())
+	depositTx, err := a.newDepositMsg(blockhash, tx, txs, sender)
 	if err != nil {
-		return fmt.Errorf("error extracting addresses: %v", err)
+		return err
 	}
-	// Deposit transaction should have only one sender
-	if len(senderAddrs) != 1 {
-		return fmt.Errorf("deposit transaction should have only one sender: %v", prevTxHash.String())
+
+	a.Log.Debug("Transaction submitted",
+		zap.Any("Tx", depositTx),
+	)
+
+	return a.SendSideTx(depositTx)
+}
+
+// Output of a transaction paying to a vault
+type vaultOutput struct {
+	Index  int
+	Vault  string
+	Amount int64
+}
+
+// Get the outputs of the transaction paying to the vaults
+func (a *State) vaultOutputs(tx *btcutil.Tx) []vaultOutput {
+	outputs := []vaultOutput{}
+	for i, txOut := range tx.MsgTx().TxOut {
+		pkScript, err := txscript.ParsePkScript(txOut.PkScript)
+		if err != nil {
+			continue
+		}
+		addr, err := pkScript.Address(a.GetChainCfg())
+		if err != nil {
+			continue
+		}
+
+		vault := btcbridge.SelectVaultByBitcoinAddress(a.params.Vaults, addr.String())
+		if vault == nil {
+			continue
+		}
+		outputs = append(outputs, vaultOutput{Index: i, Vault: vault.Address, Amount: txOut.Value})
+	}
+	return outputs
+}
+
+// Build the deposit message of the transaction from the resolved sender, with the proof of inclusion in the block
+func (a *State) newDepositMsg(blockhash *chainhash.Hash, tx *btcutil.Tx, txs []*btcutil.Tx, sender *depositSender) (*btcbridge.MsgSubmitDepositTransactionRequest, error) {
+	fields := []zap.Field{zap.String("tx", tx.Hash().String()), zap.String("sender", sender.Address)}
+	if sender.Memo != nil {
+		fields = append(fields,
+			zap.String("recipient", sender.Memo.Recipient),
+			zap.String("destination", sender.Memo.Destination),
+			zap.String("referral", sender.Memo.Referral),
+		)
 	}
+	a.Log.Info("Deposit sender resolved", fields...)
 
 	// Serialize the transaction
 	// Encode the transaction to base64
+	// The previous transaction of the first input is left empty if the sender was derived from the witness,
+	// the node doesn't have it without txindex.
 	var prevBuf bytes.Buffer
-	prevTx.MsgTx().Serialize(&prevBuf)
+	if sender.prevTx != nil {
+		sender.prevTx.MsgTx().Serialize(&prevBuf)
+	}
 
 	var buf bytes.Buffer
 	tx.MsgTx().Serialize(&buf)
@@ -62,9 +106,5 @@ func (a *State) SubmitDepositTx(blockhash *chainhash.Hash, tx *btcutil.Tx, txs [
 		Proof:       proof,
 	}
 
-	a.Log.Debug("Transaction submitted",
-		zap.Any("Tx", depositTx),
-	)
-
-	return a.SendSideTx(depositTx)
+	return depositTx, nil
 }
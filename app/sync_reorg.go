@@ -0,0 +1,148 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/sideprotocol/shuttler/app/store"
+	btcbridge "github.com/sideprotocol/side/x/btcbridge/types"
+	"go.uber.org/zap"
+)
+
+// ErrReorgTooDeep is returned when no common ancestor is found within the max reorg depth
+var ErrReorgTooDeep = errors.New("no common ancestor found within the max reorg depth")
+
+// Max depth of a reorg to be resolved automatically
+func (a *State) maxReorgDepth() int32 {
+	if a.Config.Bitcoin.MaxReorgDepth > 0 {
+		return a.Config.Bitcoin.MaxReorgDepth
+	}
+	return DefaultMaxReorgDepth
+}
+
+// Find the branch of the bitcoin network that replaces the light client chain.
+// Walk back from the given block on bitcoind and compare with the light client
+// until the common ancestor is found.
+// Returns the branch ordered from the block after the common ancestor up to the given block.
+func (a *State) findReorgBranch(block *btcjson.GetBlockHeaderVerboseResult) ([]*btcjson.GetBlockHeaderVerboseResult, error) {
+
+	lightClientTip, err := a.QueryChainTip()
+	if err != nil {
+		return nil, err
+	}
+
+	branch := []*btcjson.GetBlockHeaderVerboseResult{block}
+	current := block
+	for {
+		parentHeight := uint64(current.Height - 1)
+
+		// The light client only knows the blocks up to its tip,
+		// the blocks above it are part of the branch without counting to the depth
+		if parentHeight <= lightClientTip.Height {
+			if depth := int32(lightClientTip.Height - parentHeight); depth > a.maxReorgDepth() {
+				return nil, fmt.Errorf("%w: depth %d, max %d", ErrReorgTooDeep, depth, a.maxReorgDepth())
+			}
+
+			header, err := a.QueryBlockHeaderByHeight(parentHeight)
+			if err != nil {
+				return nil, err
+			}
+
+			// Found the common ancestor
+			if header.Hash == current.PreviousHash {
+				break
+			}
+		}
+
+		hash, err := chainhash.NewHashFromStr(current.PreviousHash)
+		if err != nil {
+			return nil, err
+		}
+		parent, err := a.rpc.GetBlockHeaderVerbose(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		branch = append(branch, parent)
+		current = parent
+	}
+
+	// Reverse the branch, the oldest block first
+	for i, j := 0, len(branch)-1; i < j; i, j = i+1, j-1 {
+		branch[i], branch[j] = branch[j], branch[i]
+	}
+
+	return branch, nil
+}
+
+// Resolve a reorg by submitting the whole replacement branch up to the given block.
+// The branch is submitted in one message, since the light client only switches
+// to a branch with more work than its current chain.
+// Header relay is halted if the reorg is deeper than the max reorg depth.
+func (a *State) handleReorg(block *btcjson.GetBlockHeaderVerboseResult) error {
+
+	branch, err := a.findReorgBranch(block)
+	if err != nil {
+		if errors.Is(err, ErrReorgTooDeep) {
+			a.halt(err)
+			a.Log.Error("Halting header relay, reorg must be resolved manually", zap.Error(err))
+		}
+		return err
+	}
+
+	a.Log.Info("===================================================================")
+	a.Log.Info("Replace the light client branch",
+		zap.Int32("from", branch[0].Height),
+		zap.Int32("to", block.Height),
+		zap.String("ancestor", branch[0].PreviousHash),
+	)
+	a.Log.Info("===================================================================")
+
+	// Make sure the bitcoin nodes agree on the replacement branch
+	if err := a.verifyQuorum(branch); err != nil {
+		return err
+	}
+
+	headers := make([]*btcbridge.BlockHeader, len(branch))
+	for i, b := range branch {
+		headers[i] = toBlockHeader(b)
+	}
+
+	if err := a.submitHeaders(headers, false); err != nil {
+		return err
+	}
+
+	a.lastBitcoinBlock = block
+	if err := a.store.SetLastHeader(&store.Header{Hash: block.Hash, Height: block.Height}); err != nil {
+		a.Log.Error("Failed to save the last relayed header", zap.Error(err))
+	}
+	if err := a.rewindScan(branch[0].Height); err != nil {
+		a.Log.Error("Failed to rewind the vault scanner", zap.Error(err))
+	}
+
+	if err := a.ScanVaultTx(); err != nil {
+		a.Log.Error("Failed to scan vault transactions", zap.Error(err))
+	}
+	return nil
+}
+
+// Rewind the vault scanner to the common ancestor of the reorg
+// The transactions of the orphaned blocks are forgotten, so that the blocks of the new branch are scanned again.
+func (a *State) rewindScan(from int32) error {
+	scanned, ok, err := a.store.ScannedHeight()
+	if err != nil {
+		return err
+	}
+	if !ok || scanned < from {
+		return nil
+	}
+
+	removed, err := a.store.UnmarkSubmittedFrom(from)
+	if err != nil {
+		return err
+	}
+	a.Log.Info("Rewinding the vault scanner", zap.Int32("scanned", scanned), zap.Int32("to", from-1), zap.Int("orphaned", removed))
+	return a.store.SetScannedHeight(min(scanned, from-1))
+}
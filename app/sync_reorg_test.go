@@ -0,0 +1,134 @@
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/sideprotocol/shuttler/app/store"
+)
+
+func Test_FindReorgBranch(t *testing.T) {
+
+	// the light client is at height 9, bitcoin forked away after height 5 up to height 12
+	lightClient := mockChain(10, 0)
+	bitcoin := mockChain(13, 1)
+	copy(bitcoin, lightClient[:6])
+
+	a := mockState(t)
+	a.rpc = &mockBackend{hashes: bitcoin}
+	a.grpcQueryClient = &mockQueryClient{headers: mockHeaders(lightClient)}
+
+	tests := []struct {
+		name     string
+		height   int
+		maxDepth int32
+		from     int32
+		err      error
+	}{
+		{"ancestor found", 9, 0, 6, nil},
+		{"branch above the light client tip", 12, 0, 6, nil},
+		{"too deep", 9, 2, 0, ErrReorgTooDeep},
+	}
+	for _, tt := range tests {
+		a.Config.Bitcoin.MaxReorgDepth = tt.maxDepth
+		block, err := a.rpc.GetBlockHeaderVerbose(&bitcoin[tt.height])
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		branch, err := a.findReorgBranch(block)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(branch) != tt.height-int(tt.from)+1 || branch[0].Height != tt.from || branch[len(branch)-1].Hash != block.Hash {
+			t.Errorf("%s: expected the branch from %d to %d, got %d blocks from %d", tt.name, tt.from, tt.height, len(branch), branch[0].Height)
+		}
+		if branch[0].PreviousHash != lightClient[tt.from-1].String() {
+			t.Errorf("%s: expected the common ancestor %s, got %s", tt.name, lightClient[tt.from-1], branch[0].PreviousHash)
+		}
+	}
+}
+
+func Test_HandleReorgTooDeep(t *testing.T) {
+
+	lightClient := mockChain(10, 0)
+	bitcoin := mockChain(13, 1)
+	copy(bitcoin, lightClient[:6])
+
+	a := mockState(t)
+	a.Config.Bitcoin.MaxReorgDepth = 2
+	a.rpc = &mockBackend{hashes: bitcoin}
+	a.grpcQueryClient = &mockQueryClient{headers: mockHeaders(lightClient)}
+
+	block, err := a.rpc.GetBlockHeaderVerbose(&bitcoin[12])
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := a.handleReorg(block); !errors.Is(err, ErrReorgTooDeep) {
+		t.Errorf("Expected %v, got %v", ErrReorgTooDeep, err)
+	}
+	if err := a.halted(); !errors.Is(err, ErrReorgTooDeep) {
+		t.Errorf("Expected the header relay to be halted, got %v", err)
+	}
+}
+
+func Test_RewindScan(t *testing.T) {
+
+	s, err := store.Open(filepath.Join(t.TempDir(), store.DefaultDBName))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer s.Close()
+
+	a := mockState(t)
+	a.store = s
+
+	// nothing scanned yet
+	if err := a.rewindScan(6); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, ok, _ := s.ScannedHeight(); ok {
+		t.Errorf("Expected no scanned height")
+	}
+
+	// the scanner is behind the common ancestor
+	if err := s.SetScannedHeight(4); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := a.rewindScan(6); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if scanned, _, _ := s.ScannedHeight(); scanned != 4 {
+		t.Errorf("Expected scanned height 4, got %d", scanned)
+	}
+
+	// the orphaned blocks have been scanned
+	if err := s.SetScannedHeight(8); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := s.MarkSubmitted(store.Deposit, "kept", 5); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := s.MarkSubmitted(store.Deposit, "orphaned", 7); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := a.rewindScan(6); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if scanned, _, _ := s.ScannedHeight(); scanned != 5 {
+		t.Errorf("Expected scanned height 5, got %d", scanned)
+	}
+	for txid, expected := range map[string]bool{"kept": true, "orphaned": false} {
+		if submitted, _ := s.IsSubmitted(store.Deposit, txid); submitted != expected {
+			t.Errorf("Expected %s submitted %v, got %v", txid, expected, submitted)
+		}
+	}
+}
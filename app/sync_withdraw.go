@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 
 	"go.uber.org/zap"
@@ -26,6 +27,12 @@ func (a *State) SignWithdrawalTxns() {
 		return
 	}
 
+	// Paused by the admin API
+	if a.isSigningPaused() {
+		a.Log.Info("Withdrawal signing paused")
+		return
+	}
+
 	// Timeout context for our queries
 	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
 	defer cancel()
@@ -155,6 +162,16 @@ func (a *State) SyncWithdrawalTxns() {
 	}
 }
 
+// Get the vault the withdrawal transaction spends from, nil if it's not a withdrawal transaction
+// The first input is spent by the vault key, with the witness of the signature and the public key.
+func withdrawalVault(vaults []*btcbridge.Vault, tx *btcutil.Tx) *btcbridge.Vault {
+	if len(tx.MsgTx().TxIn) < 1 || len(tx.MsgTx().TxIn[0].Witness) != 2 {
+		return nil
+	}
+	senderPubKey := tx.MsgTx().TxIn[0].Witness[1]
+	return btcbridge.SelectVaultByPubKey(vaults, hex.EncodeToString(senderPubKey))
+}
+
 // Submit Withdrawal Transaction to Sidechain to close the withdrawal and burn the tokens
 func (a *State) SubmitWithdrawalTx(blockhash *chainhash.Hash, tx *btcutil.Tx, txs []*btcutil.Tx) error {
 
@@ -164,6 +181,17 @@ func (a *State) SubmitWithdrawalTx(blockhash *chainhash.Hash, tx *btcutil.Tx, tx
 		return nil
 	}
 
+	withdrawalTx := a.newWithdrawalMsg(blockhash, tx, txs)
+
+	a.Log.Debug("Transaction submitted",
+		zap.Any("Tx", withdrawalTx),
+	)
+
+	return a.SendSideTx(withdrawalTx)
+}
+
+// Build the withdrawal message of the transaction, with the proof of inclusion in the block
+func (a *State) newWithdrawalMsg(blockhash *chainhash.Hash, tx *btcutil.Tx, txs []*btcutil.Tx) *btcbridge.MsgSubmitWithdrawTransactionRequest {
 	var buf bytes.Buffer
 	tx.MsgTx().Serialize(&buf)
 
@@ -177,11 +205,7 @@ func (a *State) SubmitWithdrawalTx(blockhash *chainhash.Hash, tx *btcutil.Tx, tx
 		Proof:     proof,
 	}
 
-	a.Log.Debug("Transaction submitted",
-		zap.Any("Tx", withdrawalTx),
-	)
-
-	return a.SendSideTx(withdrawalTx)
+	return withdrawalTx
 }
 
 func signPSBT(packet *psbt.Packet, privKey *secpv4.PrivateKey) (*psbt.Packet, error) {
@@ -3,6 +3,7 @@ package bitcoin
 import (
 	"github.com/btcsuite/btcd/rpcclient"
 	"github.com/sideprotocol/shuttler/app"
+	"go.uber.org/zap"
 )
 
 // @deprecated
@@ -14,28 +15,10 @@ type BTCBlockProcessor struct {
 
 func NewBlockProcessor(a *app.State) *BTCBlockProcessor {
 
-	cfg := a.Config
-	// ca, err := a.ReadCA() // Read CA certificate
-	// if err != nil {
-	// 	a.Log.Error("Failed to read CA certificate")
-	// }
-
-	// Connect to local bitcoin core RPC server using HTTP POST mode.
-	connCfg := &rpcclient.ConnConfig{
-		Host:         cfg.Bitcoin.RPC,
-		User:         cfg.Bitcoin.RPCUser,
-		Pass:         cfg.Bitcoin.RPCPassword,
-		HTTPPostMode: true, // Bitcoin core only supports HTTP POST mode
-		DisableTLS:   true, // Bitcoin core does not provide TLS by default
-		// Endpoint:     "wss", // Endpoint: ws, wss, http, https
-		// Certificates: ca,
-	}
-
-	// not supported in HTTP POST mode.
-	client, err := rpcclient.New(connCfg, nil)
+	// Connect to the bitcoin core RPC server using HTTP POST mode, with TLS or cookie auth if configured.
+	client, err := a.NewRPCClient(a.Config.Bitcoin.PrimaryNode())
 	if err != nil {
-		println(err)
-		panic("Failed to create new client")
+		a.Log.Panic("Failed to create new client", zap.Error(err))
 	}
 	// defer client.Shutdown()
 	return &BTCBlockProcessor{
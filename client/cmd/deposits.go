@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sideprotocol/shuttler/app"
+	"github.com/spf13/cobra"
+)
+
+// NewDepositsCommand returns a CLI command to review the deposits skipped by the relayer.
+func NewDepositsCommand(a *app.State) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "deposits",
+		Short: "Review the deposits skipped by the relayer",
+		Long: "Review the deposits skipped by the deposit sender or deposit policy, e.g. below the min deposit.\n" +
+			"The store is locked by a running daemon, stop it first.",
+	}
+
+	cmd.AddCommand(
+		newSkippedDepositsCommand(a),
+		newDismissDepositCommand(a),
+	)
+
+	return cmd
+}
+
+func newSkippedDepositsCommand(a *app.State) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "skipped",
+		Short: "List the skipped deposits",
+		Args:  withUsage(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return err
+			}
+
+			if err := a.InitStore(); err != nil {
+				return fmt.Errorf("failed to open the store, is the daemon running? %w", err)
+			}
+			defer a.Close()
+
+			deposits, err := a.SkippedDeposits()
+			if err != nil {
+				return err
+			}
+
+			switch output {
+			case "json":
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(deposits)
+			case "text":
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+				for _, d := range deposits {
+					fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%s\n", d.Height, d.Txid, d.Amount, d.Reason, d.Time.Format(time.RFC3339), d.Detail)
+				}
+				return w.Flush()
+			default:
+				return fmt.Errorf("unknown output format: %s", output)
+			}
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "text", "Output format (text, json)")
+
+	return cmd
+}
+
+func newDismissDepositCommand(a *app.State) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dismiss <btc-txid>",
+		Short: "Remove a reviewed deposit from the skipped deposits, e.g. after submitting it with tx submit-deposit",
+		Args:  withUsage(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := a.InitStore(); err != nil {
+				return fmt.Errorf("failed to open the store, is the daemon running? %w", err)
+			}
+			defer a.Close()
+
+			return a.DismissSkippedDeposit(args[0])
+		},
+	}
+
+	return cmd
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bufio"
+	"fmt"
 	"os"
 	"strings"
 
@@ -32,7 +33,7 @@ func NewInitCommand() *cobra.Command {
 			}
 			mnemonic := ""
 			if !generate {
-				println("Please input your mnemonic: ")
+				fmt.Fprintln(cmd.ErrOrStderr(), "Please input your mnemonic: ")
 				reader := bufio.NewReader(os.Stdin)
 				mnemonic, err = reader.ReadString('\n')
 				if err != nil {
@@ -41,8 +42,14 @@ func NewInitCommand() *cobra.Command {
 			}
 
 			cb := app.NewConfigBuilder(home)
-			cb.InitConfig(strings.TrimSpace(mnemonic), network)
-			println("\nConfiguration file created at: ", cb.ConfigFilePath())
+			cfg, mnemonic := cb.InitConfig(strings.TrimSpace(mnemonic), network)
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintln(out, "====================================================")
+			fmt.Fprintln(out, "Mnemonic: ", mnemonic)
+			fmt.Fprintln(out, "Address:  ", cfg.Side.Sender)
+			fmt.Fprintln(out, "====================================================")
+			fmt.Fprintln(out, "\nConfiguration file created at: ", cb.ConfigFilePath())
 
 			return nil
 		},
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sideprotocol/shuttler/app"
+	"github.com/spf13/cobra"
+)
+
+// Default delay between blocks of the rescan
+const DefaultRescanDelay = 200 * time.Millisecond
+
+// NewRescanCommand returns a CLI command to submit the vault transactions missed in a range of confirmed blocks.
+func NewRescanCommand(a *app.State) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rescan",
+		Short: "Rescan confirmed blocks for deposit and withdrawal transactions",
+		Long: "Rescan confirmed blocks for deposit and withdrawal transactions, and submit the ones unknown to the sidechain.\n" +
+			"The store is locked by a running daemon, use its admin API to rescan instead.",
+		Args: withUsage(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			from, err := cmd.Flags().GetInt32("from")
+			if err != nil {
+				return err
+			}
+			to, err := cmd.Flags().GetInt32("to")
+			if err != nil {
+				return err
+			}
+			resume, err := cmd.Flags().GetBool("resume")
+			if err != nil {
+				return err
+			}
+			delay, err := cmd.Flags().GetDuration("delay")
+			if err != nil {
+				return err
+			}
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return err
+			}
+
+			if err := a.InitBitcoinBackend(); err != nil {
+				return err
+			}
+			if err := a.InitStore(); err != nil {
+				return fmt.Errorf("failed to open the store, is the daemon running? %w", err)
+			}
+			defer a.Close()
+
+			// Continue after the last height of the previous rescan
+			if resume {
+				last, err := a.RescanHeight()
+				if err != nil {
+					return err
+				}
+				if last >= from {
+					from = last + 1
+				}
+			}
+
+			report, rescanErr := a.Rescan(from, to, delay)
+			if report != nil {
+				if err := printScanReport(cmd, output, report); err != nil {
+					return err
+				}
+			}
+			return rescanErr
+		},
+	}
+
+	cmd.Flags().Int32("from", 0, "First block height to rescan")
+	cmd.Flags().Int32("to", 0, "Last block height to rescan, limited to the blocks confirmed on the light client")
+	cmd.Flags().Bool("resume", false, "Resume after the last height of the previous rescan, if within the range")
+	cmd.Flags().Duration("delay", DefaultRescanDelay, "Delay between blocks, to limit the load on the bitcoin backend")
+	cmd.Flags().StringP("output", "o", "text", "Output format (text, json)")
+	cmd.MarkFlagRequired("from")
+	cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+func printScanReport(cmd *cobra.Command, output string, report *app.ScanReport) error {
+	switch output {
+	case "json":
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "text":
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		for _, tx := range report.Txs {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", tx.Height, tx.Kind, tx.Txid, tx.Result, tx.Reason)
+		}
+		fmt.Fprintf(w, "Scanned %d to %d: %d submitted, %d known, %d rejected, %d skipped\n", report.From, report.To,
+			report.Count(app.ScanResultSubmitted), report.Count(app.ScanResultKnown), report.Count(app.ScanResultRejected),
+			report.Count(app.ScanResultSkipped))
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output format: %s", output)
+	}
+}
@@ -33,6 +33,7 @@ func NewRootCmd(log *zap.Logger) *cobra.Command {
 	// Use a local app state instance scoped to the new root command,
 	// so that tests don't concurrently access the state.
 	var a = app.NewAppState("")
+	a.Log = log
 
 	// RootCmd represents the base command when called without any subcommands
 	var rootCmd = &cobra.Command{
@@ -92,6 +93,10 @@ func NewRootCmd(log *zap.Logger) *cobra.Command {
 		keys.Commands(app.DefaultHome),
 		NewInitCommand(),
 		NewStartCommand(a),
+		NewStatusCommand(a),
+		NewTxCommand(a),
+		NewRescanCommand(a),
+		NewDepositsCommand(a),
 		version.NewVersionCommand(),
 	)
 
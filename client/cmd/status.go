@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/sideprotocol/shuttler/app"
+	"github.com/spf13/cobra"
+)
+
+// NewStatusCommand returns a CLI command to print the status of the relayer on bitcoin and the sidechain.
+func NewStatusCommand(a *app.State) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of the bitcoin network, the light client and the relayer account",
+		Args:  withUsage(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return err
+			}
+
+			status := a.QueryRelayerStatus()
+
+			switch output {
+			case "json":
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(status)
+			case "text":
+				printStatus(cmd, status)
+				return nil
+			default:
+				return fmt.Errorf("unknown output format: %s", output)
+			}
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "text", "Output format (text, json)")
+
+	return cmd
+}
+
+func printStatus(cmd *cobra.Command, status *app.RelayerStatus) {
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Bitcoin tip\t%d\t%s\n", status.BitcoinHeight, status.BitcoinHash)
+	fmt.Fprintf(w, "Light client tip\t%d\t%s\n", status.LightClientHeight, status.LightClientHash)
+	fmt.Fprintf(w, "Lag\t%d\n", status.Lag)
+	fmt.Fprintf(w, "Sender\t%s\n", status.Sender)
+	fmt.Fprintf(w, "Authorized\t%t\n", status.Authorized)
+	fmt.Fprintf(w, "Balance\t%s\n", status.Balance)
+	fmt.Fprintf(w, "Sequence\t%d\n", status.Sequence)
+
+	for _, v := range status.Vaults {
+		fmt.Fprintf(w, "Vault\t%s\t%s\tv%d\n", v.Address, v.AssetType, v.Version)
+	}
+
+	statuses := make([]string, 0, len(status.SigningRequests))
+	for s := range status.SigningRequests {
+		statuses = append(statuses, s)
+	}
+	sort.Strings(statuses)
+	for _, s := range statuses {
+		fmt.Fprintf(w, "Signing requests\t%s\t%d\n", s, status.SigningRequests[s])
+	}
+
+	for _, err := range status.Errors {
+		fmt.Fprintf(w, "Error\t%s\n", err)
+	}
+
+	w.Flush()
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/sideprotocol/shuttler/app"
+	"github.com/spf13/cobra"
+)
+
+// NewTxCommand returns a CLI command to submit bitcoin transactions missed by the relayer.
+func NewTxCommand(a *app.State) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tx",
+		Short: "Submit bitcoin transactions to the sidechain manually",
+	}
+
+	cmd.AddCommand(
+		newSubmitDepositCommand(a),
+		newSubmitWithdrawalCommand(a),
+	)
+
+	return cmd
+}
+
+func newSubmitDepositCommand(a *app.State) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "submit-deposit <btc-txid>",
+		Short: "Submit a deposit transaction paying to a vault, with the proof of inclusion in its block",
+		Args:  withUsage(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			blockHash, err := cmd.Flags().GetString("block-hash")
+			if err != nil {
+				return err
+			}
+
+			if err := a.InitBitcoinBackend(); err != nil {
+				return err
+			}
+
+			msg, err := a.BuildDepositMsg(args[0], blockHash)
+			if err != nil {
+				return err
+			}
+			return submitMsg(cmd, a, msg)
+		},
+	}
+
+	cmd.Flags().String("block-hash", "", "Hash of the block including the transaction, looked up from the bitcoin backend if empty")
+	addDryRunFlag(cmd)
+
+	return cmd
+}
+
+func newSubmitWithdrawalCommand(a *app.State) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "submit-withdrawal <btc-txid>",
+		Short: "Submit a withdrawal transaction spending from a vault, with the proof of inclusion in its block",
+		Args:  withUsage(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			blockHash, err := cmd.Flags().GetString("block-hash")
+			if err != nil {
+				return err
+			}
+
+			if err := a.InitBitcoinBackend(); err != nil {
+				return err
+			}
+
+			msg, err := a.BuildWithdrawalMsg(args[0], blockHash)
+			if err != nil {
+				return err
+			}
+			return submitMsg(cmd, a, msg)
+		},
+	}
+
+	cmd.Flags().String("block-hash", "", "Hash of the block including the transaction, looked up from the bitcoin backend if empty")
+	addDryRunFlag(cmd)
+
+	return cmd
+}
+
+func addDryRunFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("dry-run", false, "Print the message instead of sending it")
+}
+
+// Send the message to the sidechain, or print it with --dry-run
+func submitMsg(cmd *cobra.Command, a *app.State, msg sdk.Msg) error {
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(msg)
+	}
+
+	res, err := a.BroadcastSideTx(msg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "Transaction broadcasted:", res.TxHash)
+	return nil
+}
@@ -1,6 +1,7 @@
 package relayer
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
@@ -8,32 +9,43 @@ import (
 
 	"github.com/sideprotocol/shuttler/app"
 	"go.uber.org/zap"
-
-	zmqclient "github.com/ordishs/go-bitcoin"
 )
 
 func Start(a *app.State) {
 
-	// Create a new ZMQ client & RPC client
-	host := a.Config.Bitcoin.ZMQHost
-	port := a.Config.Bitcoin.ZMQPort
-	if host == "" || port == 0 {
-		panic("ZMQ host or port not set")
-	}
-
 	a.Log.Info("Connecting to the Side and Bitcoin network...")
 	err := a.InitRPC()
 	if err != nil {
 		panic(err)
 	}
 
+	err = a.InitStore()
+	if err != nil {
+		panic(err)
+	}
+	defer a.Close()
+
+	// Serve the metrics and health endpoints until exiting
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a.StartHTTPServers(ctx)
+	if err := a.StartAdminServer(ctx); err != nil {
+		panic(err)
+	}
+
+	// Create the source of new blocks, ZMQ or RPC polling
+	source, err := a.NewBlockSource()
+	if err != nil {
+		panic(err)
+	}
+	defer source.Close()
+
 	// 1. Sync the light client with the bitcoin network
 	a.FastSyncLightClient()
 
 	// 2. Subscribe to the latest block
-	zmq := zmqclient.NewZMQ(host, port)
 	btcChan := make(chan []string)
-	if err := zmq.Subscribe("hashblock", btcChan); err != nil {
+	if err := source.Subscribe(btcChan); err != nil {
 		a.Log.Fatal("%v", zap.Error(err))
 	}
 	a.Log.Info("Waiting for blocks...")
@@ -50,12 +62,18 @@ func Start(a *app.State) {
 		select {
 		case c := <-btcChan:
 			a.OnNewBtcBlock(c)
+		case fn := <-a.AdminRequests():
+			fn()
 		case <-sigs:
 			a.Log.Info("Exiting...")
 			return
 		case <-ticker.C:
+			if err := a.ScanVaultTx(); err != nil {
+				a.Log.Error("Failed to scan vault transactions", zap.Error(err))
+			}
 			a.SignWithdrawalTxns()
 			a.SyncWithdrawalTxns()
+			a.UpdateMetrics()
 		}
 	}
 	// return nil